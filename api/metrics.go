@@ -0,0 +1,26 @@
+package api
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// listQuotaCost is the quota units cost of a list request of the YouTube Data API
+const listQuotaCost = 1
+
+var (
+	apiCalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "yt_dl_go_youtube_api_calls_total",
+		Help: "YouTube Data API calls by method.",
+	}, []string{"method"})
+	apiQuotaUnits = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "yt_dl_go_youtube_api_quota_units_total",
+		Help: "Estimated YouTube Data API quota units used.",
+	})
+)
+
+// countCall records a YouTube Data API request
+func countCall(method string, cost int) {
+	apiCalls.WithLabelValues(method).Inc()
+	apiQuotaUnits.Add(float64(cost))
+}
@@ -1,8 +1,11 @@
 package api
 
 import (
+	"errors"
 	"github.com/bit-twit/yt-dl-go/types"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/youtube/v3"
+	"net/http"
 )
 
 type YoutubeAPI struct {
@@ -10,34 +13,34 @@ type YoutubeAPI struct {
 	service *youtube.Service
 }
 
-func NewYoutubeAPI(c types.Config) *YoutubeAPI {
+// NewYoutubeAPI creates the API client, OAuth failures are returned as *types.AuthError
+func NewYoutubeAPI(c types.Config) (*YoutubeAPI, error) {
 	oauthClient, err := GetOAuth2HTTPClient(c.ClientSecretPath)
 	if err != nil {
-		panic(err)
+		return nil, &types.AuthError{Err: err}
 	}
-	httpURLPath := "http"
-	if c.HTTPSEnabled {
-		httpURLPath += "s"
-	}
-	if googleAPI, err := youtube.New(oauthClient); err == nil {
-		youtubeAPI := &YoutubeAPI{
-			config:  c,
-			service: googleAPI,
-		}
-		return youtubeAPI
-	} else {
-		panic(err)
+	googleAPI, err := youtube.New(oauthClient)
+	if err != nil {
+		return nil, err
 	}
+	return &YoutubeAPI{
+		config:  c,
+		service: googleAPI,
+	}, nil
 }
 
 func (y *YoutubeAPI) ListPlaylists() ([]string, error) {
 	req := y.service.Playlists.List([]string{"id, snippet,status"})
+	countCall("playlists.list", listQuotaCost)
 	resp, err := req.Mine(true).Do()
+	if err != nil {
+		return nil, wrapError(err)
+	}
 	results := make([]string, len(resp.Items), len(resp.Items))
 	for i, _ := range resp.Items {
 		results[i] = resp.Items[i].Id
 	}
-	return results, err
+	return results, nil
 }
 
 func (y *YoutubeAPI) ListVideosForPlaylist(playlistId string, maxResults int64) ([]types.Video, error) {
@@ -45,7 +48,11 @@ func (y *YoutubeAPI) ListVideosForPlaylist(playlistId string, maxResults int64)
 		List([]string{"id,snippet,status"}).
 		PlaylistId(playlistId).
 		MaxResults(maxResults)
+	countCall("playlistItems.list", listQuotaCost)
 	resp, err := req.Do()
+	if err != nil {
+		return nil, wrapError(err)
+	}
 	results := make([]types.Video, 0, len(resp.Items))
 
 	responseProcessor := func(playlistItemResponse *youtube.PlaylistItemListResponse) {
@@ -56,6 +63,7 @@ func (y *YoutubeAPI) ListVideosForPlaylist(playlistId string, maxResults int64)
 			if el.Snippet.ResourceId.Kind == "youtube#video" {
 				results = append(results, types.Video{
 					ID:          el.Snippet.ResourceId.VideoId,
+					PlaylistID:  playlistId,
 					Title:       el.Snippet.Title,
 					Description: el.Snippet.ResourceId.Kind,
 				})
@@ -67,23 +75,25 @@ func (y *YoutubeAPI) ListVideosForPlaylist(playlistId string, maxResults int64)
 	if resp.NextPageToken != "" {
 		for resp.NextPageToken != "" && len(results) < int(maxResults) {
 			req = req.PageToken(resp.NextPageToken)
+			countCall("playlistItems.list", listQuotaCost)
 			resp, err = req.Do()
 			if err != nil {
-				break
+				return results, wrapError(err)
 			}
 			responseProcessor(resp)
 		}
 	}
 
-	return results, err
+	return results, nil
 }
 
 func (y *YoutubeAPI) ListVideosLiked(maxResults int64) ([]types.Video, error) {
 	req := y.service.Videos.
 		List([]string{"snippet,contentDetails,statistics"}).MyRating("like").MaxResults(maxResults)
+	countCall("videos.list", listQuotaCost)
 	resp, err := req.Do()
 	if err != nil {
-		return nil, err
+		return nil, wrapError(err)
 	}
 	results := make([]types.Video, 0, len(resp.Items))
 
@@ -103,13 +113,23 @@ func (y *YoutubeAPI) ListVideosLiked(maxResults int64) ([]types.Video, error) {
 	if resp.NextPageToken != "" {
 		for resp.NextPageToken != "" && len(results) < int(maxResults) {
 			req = req.PageToken(resp.NextPageToken)
+			countCall("videos.list", listQuotaCost)
 			resp, err = req.Do()
 			if err != nil {
-				break
+				return results, wrapError(err)
 			}
 			responseProcessor(resp)
 		}
 	}
 
-	return results, err
+	return results, nil
+}
+
+// wrapError marks the API errors caused by rejected credentials as *types.AuthError
+func wrapError(err error) error {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusUnauthorized {
+		return &types.AuthError{Err: err}
+	}
+	return err
 }
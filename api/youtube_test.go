@@ -5,6 +5,7 @@ import (
 	"github.com/bit-twit/yt-dl-go/types"
 	"github.com/bit-twit/yt-dl-go/utils"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"testing"
 )
 
@@ -14,13 +15,14 @@ const (
 )
 
 func TestListPlaylists(t *testing.T) {
-	yt := NewYoutubeAPI(types.Config{
-		true,
-		"youtube.googleapis.com",
-		443,
-		utils.GetEnv("YOUTUBE_API_KEY", ""),
-		defaultClientSecret,
+	yt, err := NewYoutubeAPI(types.Config{
+		HTTPSEnabled:     true,
+		Host:             "youtube.googleapis.com",
+		Port:             443,
+		ApiKey:           utils.GetEnv("YOUTUBE_API_KEY", ""),
+		ClientSecretPath: defaultClientSecret,
 	})
+	require.NoError(t, err)
 
 	res, err := yt.ListPlaylists()
 	assert.NoError(t, err)
@@ -28,13 +30,14 @@ func TestListPlaylists(t *testing.T) {
 }
 
 func TestListVideosForPlaylist(t *testing.T) {
-	yt := NewYoutubeAPI(types.Config{
-		true,
-		"youtube.googleapis.com",
-		443,
-		utils.GetEnv("YOUTUBE_API_KEY", ""),
-		defaultClientSecret,
+	yt, err := NewYoutubeAPI(types.Config{
+		HTTPSEnabled:     true,
+		Host:             "youtube.googleapis.com",
+		Port:             443,
+		ApiKey:           utils.GetEnv("YOUTUBE_API_KEY", ""),
+		ClientSecretPath: defaultClientSecret,
 	})
+	require.NoError(t, err)
 
 	vids, err := yt.ListVideosForPlaylist(defaultPlaylistId, 5)
 	assert.NoError(t, err)
@@ -43,13 +46,14 @@ func TestListVideosForPlaylist(t *testing.T) {
 }
 
 func TestListVideosLiked(t *testing.T) {
-	yt := NewYoutubeAPI(types.Config{
-		true,
-		"youtube.googleapis.com",
-		443,
-		utils.GetEnv("YOUTUBE_API_KEY", ""),
-		defaultClientSecret,
+	yt, err := NewYoutubeAPI(types.Config{
+		HTTPSEnabled:     true,
+		Host:             "youtube.googleapis.com",
+		Port:             443,
+		ApiKey:           utils.GetEnv("YOUTUBE_API_KEY", ""),
+		ClientSecretPath: defaultClientSecret,
 	})
+	require.NoError(t, err)
 
 	vids, err := yt.ListVideosLiked(5)
 	assert.NoError(t, err)
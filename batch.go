@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// inlineCommentPattern matches a comment after the entry, the # must follow a blank as urls may contain fragments
+var inlineCommentPattern = regexp.MustCompile(`\s+#.*$`)
+
+// batchEntry is a video url or id read from a batch file
+type batchEntry struct {
+	Line  int
+	Input string
+}
+
+// readBatchFile reads the batch entries from a file, or from stdin when path is -
+func readBatchFile(path string) ([]batchEntry, error) {
+	if path == "-" {
+		return readBatch(os.Stdin)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readBatch(f)
+}
+
+// readBatch reads one url or id per line, skipping blank lines and # comments
+func readBatch(r io.Reader) ([]batchEntry, error) {
+	var entries []batchEntry
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		input := strings.TrimSpace(inlineCommentPattern.ReplaceAllString(scanner.Text(), ""))
+		if input == "" || strings.HasPrefix(input, "#") {
+			continue
+		}
+		entries = append(entries, batchEntry{Line: line, Input: input})
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadBatch(t *testing.T) {
+	input := `# archive list
+BaW_jenozKc
+
+  https://youtu.be/QcHvzNBtlOw   # metallica
+https://www.youtube.com/watch?v=BaW_jenozKc#t=5
+	# indented comment
+not-a-video-id
+`
+	entries, err := readBatch(strings.NewReader(input))
+	require.NoError(t, err)
+	assert.Equal(t, []batchEntry{
+		{Line: 2, Input: "BaW_jenozKc"},
+		{Line: 4, Input: "https://youtu.be/QcHvzNBtlOw"},
+		{Line: 5, Input: "https://www.youtube.com/watch?v=BaW_jenozKc#t=5"},
+		{Line: 7, Input: "not-a-video-id"},
+	}, entries)
+}
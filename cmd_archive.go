@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bit-twit/yt-dl-go/downloader"
+	"os"
+)
+
+func newArchiveCommand() *command {
+	cmd := newCommand("archive", "import <file>",
+		"Import a yt-dlp download archive",
+		"Add the videos of a yt-dlp --download-archive file, or of another archive, to the archive set by -archive.\n"+
+			"The imported videos are skipped by download and sync whatever the format.")
+	cfg := registerConfigFlags(cmd.flags).registerArchive()
+
+	cmd.run = func(ctx context.Context, args []string) error {
+		if err := requireArgs(cmd, args, 2); err != nil {
+			return err
+		}
+		if args[0] != "import" {
+			cmd.flags.Usage()
+			return configError(fmt.Errorf("unknown archive command %q", args[0]))
+		}
+
+		c, err := cfg.load()
+		if err != nil {
+			return err
+		}
+		if c.Archive == "" {
+			return configError(errors.New("expected archive in config, YT_DL_GO_ARCHIVE env or -archive param"))
+		}
+		archive, err := downloader.OpenArchive(c.Archive)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Open(args[1])
+		if err != nil {
+			return configError(err)
+		}
+		defer f.Close()
+		n, err := archive.Import(f)
+		if err != nil {
+			return fmt.Errorf("unable to import %s: %w", args[1], err)
+		}
+		fmt.Fprintf(os.Stdout, "Imported %d entries from %s, %d videos in %s\n", n, args[1], archive.Len(), c.Archive)
+		return nil
+	}
+	return cmd
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/bit-twit/yt-dl-go/config"
+	"os"
+)
+
+func newConfigCommand() *command {
+	cmd := newCommand("config", "show",
+		"Show the merged configuration",
+		"Print the configuration resulting from the config file, the env vars and the flags, in this order of precedence.\n"+
+			"The config file defaults to config.yaml in the yt-dl-go folder of the user config dir.")
+	cfg := registerConfigFlags(cmd.flags).registerAPI().registerDownload().registerArchive().registerNetwork()
+
+	cmd.run = func(ctx context.Context, args []string) error {
+		if err := requireArgs(cmd, args, 1); err != nil {
+			return err
+		}
+		if args[0] != "show" {
+			cmd.flags.Usage()
+			return configError(fmt.Errorf("unknown config command %q", args[0]))
+		}
+
+		c, err := cfg.load()
+		if err != nil {
+			return err
+		}
+		b, err := config.Marshal(c)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "# %s\n%s", *cfg.path, b)
+		return nil
+	}
+	return cmd
+}
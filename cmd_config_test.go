@@ -0,0 +1,14 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigCommand_UnknownSubcommand(t *testing.T) {
+	cmd := newConfigCommand()
+	err := cmd.run(context.Background(), parseArgs(cmd.flags, []string{"edit"}))
+	assert.Equal(t, exitConfig, exitCode(err))
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bit-twit/yt-dl-go/downloader"
+	"github.com/bit-twit/yt-dl-go/types"
+	"io"
+	"os"
+)
+
+// downloadResult holds the outcome of downloading a single video
+type downloadResult struct {
+	Video types.Video
+	Line  int // line of the batch file listing the video, 0 for other sources
+	File  string
+	Plan  *downloader.DownloadPlan // set instead of File in dry-run mode
+	// Skipped is set for the videos found in the archive, and with Duplicate for the videos given twice
+	Skipped   bool
+	Duplicate bool
+	Err       error
+}
+
+func newDownloadCommand() *command {
+	cmd := newCommand("download", "<id|url>...",
+		"Download one or more videos",
+		"Download the given videos into the output directory, in the preferred format or as mp3 audio.\n"+
+			"Without format preferences the first video/mp4 format is downloaded.\n"+
+			"Videos can also be read from a batch file, one url or id per line, # starts a comment.\n"+
+			"With -archive the videos already downloaded in the same format are skipped.\n"+
+			"With -o - a single video is written to stdout, eg: download -o - <id> | mpv -")
+	cfg := registerConfigFlags(cmd.flags).registerDownload().registerArchive().registerNetwork()
+	batchFile := cmd.flags.String("batchFile", "", "Read the videos to download from this file, - for stdin.")
+	dryRun := cmd.flags.Bool("dryRun", false, "Show the chosen format, destination and size of every video without downloading.")
+	output := cmd.flags.String("o", "", "Write the video to stdout with -, the logs, progress and report go to stderr.\n"+
+		"In audio only mode the mp4 audio stream is written without conversion.")
+
+	cmd.run = func(ctx context.Context, args []string) error {
+		var entries []batchEntry
+		if *batchFile != "" {
+			var err error
+			if entries, err = readBatchFile(*batchFile); err != nil {
+				return configError(err)
+			}
+		} else if err := requireArgs(cmd, args, 1); err != nil {
+			return err
+		}
+
+		toStdout := *output == "-"
+		switch {
+		case *output != "" && !toStdout:
+			return configError(fmt.Errorf("-o only accepts - to write to stdout, got %q", *output))
+		case toStdout && len(args)+len(entries) != 1:
+			return configError(errors.New("-o - writes a single video to stdout"))
+		case toStdout && *dryRun:
+			return configError(errors.New("-o - and -dryRun can't be used together"))
+		}
+
+		c, err := cfg.load()
+		if err != nil {
+			return err
+		}
+		dl, err := newDownloader(c)
+		if err != nil {
+			return err
+		}
+		if !*dryRun && !toStdout {
+			sweepTempFiles(dl)
+		}
+
+		vs := make([]types.Video, 0, len(args)+len(entries))
+		for _, id := range args {
+			vs = append(vs, types.Video{ID: id})
+		}
+		for _, e := range entries {
+			vs = append(vs, types.Video{ID: e.Input})
+		}
+
+		if toStdout {
+			bars := newProgressBars(os.Stderr)
+			r := streamVideo(downloader.WithProgressListener(ctx, bars), dl, vs[0].ID, c.Format, os.Stdout)
+			bars.stop()
+			var report runReport
+			report.addDownloads(r)
+			report.print(os.Stderr)
+			return report.err()
+		}
+
+		lines := make([]int, len(vs))
+		for i, e := range entries {
+			lines[len(args)+i] = e.Line
+		}
+		opts := downloader.DownloadOptions{Format: c.Format, DryRun: *dryRun}
+		var bars *progressBars
+		if !*dryRun {
+			bars = newProgressBars(os.Stdout)
+			opts.Progress = bars
+		}
+		results := downloadVideos(ctx, dl, vs, lines, opts)
+		var report runReport
+		report.addDownloads(results...)
+		bars.stop()
+		report.print(os.Stdout)
+		return report.err()
+	}
+	return cmd
+}
+
+// downloadVideos downloads the videos in parallel, until the context is done, and returns the results in the
+// order of the videos. lines gives the batch file line of every video, it can be nil.
+func downloadVideos(ctx context.Context, dl *downloader.Downloader, vs []types.Video, lines []int, opts downloader.DownloadOptions) []downloadResult {
+	byIndex := make([]*downloadResult, len(vs))
+	for r := range dl.DownloadAll(ctx, vs, opts) {
+		byIndex[r.Index] = &downloadResult{Video: r.Video, File: r.File, Plan: r.Plan, Skipped: r.Skipped,
+			Duplicate: r.Duplicate, Err: r.Err}
+		if lines != nil {
+			byIndex[r.Index].Line = lines[r.Index]
+		}
+	}
+
+	results := make([]downloadResult, 0, len(vs))
+	for _, r := range byIndex {
+		if r != nil {
+			results = append(results, *r)
+		}
+	}
+	return results
+}
+
+// streamVideo writes the preferred format of the video to w, or its mp4 audio stream in audio only mode
+func streamVideo(ctx context.Context, dl *downloader.Downloader, id string, f types.FormatConfig, w io.Writer) downloadResult {
+	r := downloadResult{Video: types.Video{ID: id}, File: "-"}
+	v, err := dl.GetVideoInfo(ctx, id)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	r.Video = types.Video{ID: v.ID, Title: v.Title}
+
+	format := v.Formats.Select(f)
+	if f.AudioOnly {
+		format = v.Formats.FindByItag(140)
+	}
+	if format == nil {
+		r.Err = fmt.Errorf("%w: %+v", types.ErrFormatNotFound, f)
+		return r
+	}
+	_, r.Err = dl.DownloadTo(ctx, v, format, w)
+	return r
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadCommand_StdoutUsage(t *testing.T) {
+	for _, args := range [][]string{
+		{"-o", "video.mp4", "BaW_jenozKc"},
+		{"-o", "-", "BaW_jenozKc", "QcHvzNBtlOw"},
+		{"-o", "-", "-dryRun", "BaW_jenozKc"},
+	} {
+		cmd := newDownloadCommand()
+		err := cmd.run(context.Background(), parseArgs(cmd.flags, args))
+		assert.Equal(t, exitConfig, exitCode(err), "%v: %v", args, err)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"os"
+)
+
+func newFormatsCommand() *command {
+	cmd := newCommand("formats", "<id|url>",
+		"List the available formats of a video",
+		"Fetch the video info and print every format that can be downloaded, with its kind:\n"+
+			"progressive (audio and video), video-only or audio-only adaptive streams.\n"+
+			"Use the itag column to pick a specific format.")
+	cfg := registerConfigFlags(cmd.flags).registerNetwork()
+	output := registerOutputFlag(cmd.flags)
+
+	cmd.run = func(ctx context.Context, args []string) error {
+		if err := requireArgs(cmd, args, 1); err != nil {
+			return err
+		}
+		format, err := parseOutputFormat(*output)
+		if err != nil {
+			return err
+		}
+		c, err := cfg.load()
+		if err != nil {
+			return err
+		}
+		dl, err := newDownloader(c)
+		if err != nil {
+			return err
+		}
+
+		v, err := dl.GetVideoInfo(ctx, args[0])
+		if err != nil {
+			return err
+		}
+
+		infos := v.Formats.Infos()
+		switch format {
+		case outputJSON:
+			return writeJSON(os.Stdout, infos)
+		case outputNDJSON:
+			items := make([]interface{}, len(infos))
+			for i := range infos {
+				items[i] = infos[i]
+			}
+			return writeNDJSON(os.Stdout, items...)
+		}
+		return v.Formats.WriteTable(os.Stdout)
+	}
+	return cmd
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/bit-twit/yt-dl-go/types"
+	"os"
+)
+
+func newInfoCommand() *command {
+	cmd := newCommand("info", "<id|url>...",
+		"Show the details of one or more videos",
+		"Fetch and print the title, author, duration and available formats count of the videos.\n"+
+			"The json and ndjson outputs include the complete format list.")
+	cfg := registerConfigFlags(cmd.flags).registerNetwork()
+	output := registerOutputFlag(cmd.flags)
+
+	cmd.run = func(ctx context.Context, args []string) error {
+		if err := requireArgs(cmd, args, 1); err != nil {
+			return err
+		}
+		format, err := parseOutputFormat(*output)
+		if err != nil {
+			return err
+		}
+		c, err := cfg.load()
+		if err != nil {
+			return err
+		}
+		dl, err := newDownloader(c)
+		if err != nil {
+			return err
+		}
+
+		vs := make([]types.Video, 0, len(args))
+		for _, id := range args {
+			v, err := dl.GetVideoInfo(ctx, id)
+			if err != nil {
+				return err
+			}
+			vs = append(vs, *v)
+		}
+
+		switch format {
+		case outputJSON:
+			return writeJSON(os.Stdout, vs)
+		case outputNDJSON:
+			items := make([]interface{}, len(vs))
+			for i := range vs {
+				items[i] = vs[i]
+			}
+			return writeNDJSON(os.Stdout, items...)
+		}
+
+		for _, v := range vs {
+			fmt.Printf("ID:       %s\n", v.ID)
+			fmt.Printf("Title:    %s\n", v.Title)
+			fmt.Printf("Author:   %s\n", v.Author)
+			fmt.Printf("Duration: %s\n", v.Duration)
+			fmt.Printf("Formats:  %d\n", len(v.Formats))
+		}
+		return nil
+	}
+	return cmd
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/bit-twit/yt-dl-go/api"
+	"github.com/bit-twit/yt-dl-go/types"
+	"io"
+	"os"
+)
+
+// listingFlags select which playlists are listed from the user account
+type listingFlags struct {
+	maxResults *int64
+	playlistId *string
+	liked      *bool
+}
+
+func registerListingFlags(fs *flag.FlagSet) *listingFlags {
+	return &listingFlags{
+		maxResults: fs.Int64("maxResults", 5, "The maximum number of video resources to fetch from each playlist."),
+		playlistId: fs.String("playlistId", "", "Retrieve information about specific playlist, by id or url - otherwise it will retrieve all users's playlist."),
+		liked:      fs.Bool("liked", true, "Retrieve videos from special liked playlist."),
+	}
+}
+
+// validate replaces the playlist url given with -playlistId by the playlist id
+func (f *listingFlags) validate() error {
+	if *f.playlistId == "" {
+		return nil
+	}
+	id, err := types.ExtractPlaylistID(*f.playlistId)
+	if err != nil {
+		return configError(fmt.Errorf("%w: %s", err, *f.playlistId))
+	}
+	*f.playlistId = id
+	return nil
+}
+
+// playlistIDs returns the requested playlist or all the playlists of the user
+func (f *listingFlags) playlistIDs(yt *api.YoutubeAPI) ([]string, error) {
+	if *f.playlistId != "" {
+		return []string{*f.playlistId}, nil
+	}
+	// fetch all mine
+	return yt.ListPlaylists()
+}
+
+// playlistListing is the list command result for a single playlist
+type playlistListing struct {
+	ID     string        `json:"id,omitempty"`
+	Liked  bool          `json:"liked,omitempty"` // the special liked videos playlist, without id
+	Videos []types.Video `json:"videos"`
+}
+
+func newListCommand() *command {
+	cmd := newCommand("list", "",
+		"List videos from your playlists and liked videos",
+		"List the videos found in the playlists of the authenticated Youtube account and in its liked videos.\n"+
+			"With -output=ndjson every video is printed on its own line, liked videos have no playlistId.")
+	cfg := registerConfigFlags(cmd.flags).registerAPI()
+	listing := registerListingFlags(cmd.flags)
+	output := registerOutputFlag(cmd.flags)
+
+	cmd.run = func(ctx context.Context, args []string) error {
+		format, err := parseOutputFormat(*output)
+		if err != nil {
+			return err
+		}
+
+		if err := listing.validate(); err != nil {
+			return err
+		}
+		c, err := cfg.load()
+		if err != nil {
+			return err
+		}
+
+		yt, err := newYoutubeAPI(c)
+		if err != nil {
+			return err
+		}
+
+		ps, err := listing.playlistIDs(yt)
+		if err != nil {
+			return err
+		}
+
+		// fetch video information from playlists
+		var report runReport
+		listings := make([]playlistListing, 0, len(ps)+1)
+		for _, p := range ps {
+			pVideos, err := yt.ListVideosForPlaylist(p, *listing.maxResults)
+			report.addPlaylist(p, err)
+			if err == nil {
+				listings = append(listings, playlistListing{ID: p, Videos: pVideos})
+			}
+		}
+
+		// fetch liked videos
+		if *listing.liked {
+			lVideos, err := yt.ListVideosLiked(*listing.maxResults)
+			report.addPlaylist("", err)
+			if err == nil {
+				listings = append(listings, playlistListing{Liked: true, Videos: lVideos})
+			}
+		}
+
+		if err := printListings(os.Stdout, format, listings); err != nil {
+			return err
+		}
+		report.print(os.Stderr)
+		return report.err()
+	}
+	return cmd
+}
+
+func printListings(w io.Writer, format outputFormat, listings []playlistListing) error {
+	switch format {
+	case outputJSON:
+		return writeJSON(w, listings)
+	case outputNDJSON:
+		var items []interface{}
+		for _, l := range listings {
+			for _, v := range l.Videos {
+				items = append(items, v)
+			}
+		}
+		return writeNDJSON(w, items...)
+	}
+
+	total := 0
+	for _, l := range listings {
+		if l.Liked {
+			fmt.Fprintf(w, "Liked videos (%d):\n", len(l.Videos))
+		} else {
+			fmt.Fprintf(w, "Playlist %s (%d):\n", l.ID, len(l.Videos))
+		}
+		for _, v := range l.Videos {
+			fmt.Fprintf(w, "  %s %s\n", v.ID, v.Title)
+		}
+		total += len(l.Videos)
+	}
+	fmt.Fprintf(w, "Found %d videos\n", total)
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"github.com/bit-twit/yt-dl-go/server"
+	"log"
+	"net/http"
+	"time"
+)
+
+// shutdownTimeout is how long the server waits for running requests when stopping
+const shutdownTimeout = 10 * time.Second
+
+func newServeCommand() *command {
+	cmd := newCommand("serve", "",
+		"Run an HTTP server with a REST download job API",
+		"Run a download server, jobs are processed by a pool of workers:\n"+
+			"  GET    /                  the web UI\n"+
+			"  GET    /info?url=         get a video and its formats\n"+
+			"  POST   /jobs              queue a download: {\"url\": \"...\", \"format\": {\"itag\": 18}, \"outputFile\": \"...\"}\n"+
+			"  GET    /jobs              list the jobs\n"+
+			"  GET    /jobs/{id}         get a job status\n"+
+			"  DELETE /jobs/{id}         cancel a queued or running job\n"+
+			"  GET    /jobs/{id}/events  stream the job status and progress as server-sent events\n"+
+			"  GET    /files/...         download the files of the output directory\n"+
+			"  GET    /stream/{videoID}  proxy the stream of the ?itag= format\n"+
+			"  GET    /metrics           Prometheus metrics\n"+
+			"  GET    /healthz, /readyz  health checks: ffmpeg, output directory and workers")
+	addr := cmd.flags.String("addr", ":8080", "The address the HTTP server listens on.")
+	workers := cmd.flags.Int("workers", 2, "The number of jobs downloaded in parallel.")
+	cfg := registerConfigFlags(cmd.flags).registerDownload().registerArchive().registerNetwork()
+
+	cmd.run = func(ctx context.Context, args []string) error {
+		c, err := cfg.load()
+		if err != nil {
+			return err
+		}
+		dl, err := newDownloader(c)
+		if err != nil {
+			return err
+		}
+		sweepTempFiles(dl)
+
+		users, err := server.UsersFromConfig(c.Server.Users)
+		if err != nil {
+			return configError(err)
+		}
+		if len(users) == 0 {
+			log.Printf("no server users configured, the server doesn't require authentication")
+		}
+
+		jobs := server.NewManager(dl, *workers, c.Format)
+		jobs.Start(ctx)
+
+		srv := &http.Server{Addr: *addr, Handler: server.New(jobs, c.OutputDir, users)}
+		err = listenAndServe(ctx, srv, c.OutputDir)
+		// let the running jobs stop
+		jobs.Wait()
+		return err
+	}
+	return cmd
+}
+
+// listenAndServe runs the server until the context is done, then waits for the running requests to complete
+func listenAndServe(ctx context.Context, srv *http.Server, outputDir string) error {
+	errc := make(chan error, 1)
+	go func() {
+		log.Printf("serving %s on %s", outputDir, srv.Addr)
+		errc <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	}
+}
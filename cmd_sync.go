@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"github.com/bit-twit/yt-dl-go/downloader"
+	"os"
+	"path/filepath"
+)
+
+// likedDirName is the folder used to mirror the special liked videos playlist
+const likedDirName = "liked"
+
+func newSyncCommand() *command {
+	cmd := newCommand("sync", "",
+		"Mirror your playlists into the output directory",
+		"Download the videos of the account playlists and liked videos, each playlist into its own folder of the output directory.")
+	cfg := registerConfigFlags(cmd.flags).registerAPI().registerDownload().registerArchive().registerNetwork()
+	listing := registerListingFlags(cmd.flags)
+	dryRun := cmd.flags.Bool("dryRun", false, "Show the chosen format, destination and size of every video without downloading.")
+
+	cmd.run = func(ctx context.Context, args []string) error {
+		if err := listing.validate(); err != nil {
+			return err
+		}
+		c, err := cfg.load()
+		if err != nil {
+			return err
+		}
+
+		yt, err := newYoutubeAPI(c)
+		if err != nil {
+			return err
+		}
+
+		ps, err := listing.playlistIDs(yt)
+		if err != nil {
+			return err
+		}
+
+		dl, err := newDownloader(c)
+		if err != nil {
+			return err
+		}
+		if !*dryRun {
+			sweepTempFiles(dl)
+		}
+
+		opts := downloader.DownloadOptions{Format: c.Format, DryRun: *dryRun}
+		var bars *progressBars
+		if !*dryRun {
+			bars = newProgressBars(os.Stdout)
+			opts.Progress = bars
+		}
+		report := runReport{downloadsReported: true}
+		for _, p := range ps {
+			vs, err := yt.ListVideosForPlaylist(p, *listing.maxResults)
+			report.addPlaylist(p, err)
+			if err != nil {
+				continue
+			}
+			report.addDownloads(downloadVideos(ctx, playlistDownloader(dl, p), vs, nil, opts)...)
+		}
+
+		if *listing.liked {
+			vs, err := yt.ListVideosLiked(*listing.maxResults)
+			report.addPlaylist("", err)
+			if err == nil {
+				report.addDownloads(downloadVideos(ctx, playlistDownloader(dl, likedDirName), vs, nil, opts)...)
+			}
+		}
+
+		bars.stop()
+		report.print(os.Stdout)
+		return report.err()
+	}
+	return cmd
+}
+
+// playlistDownloader returns a downloader storing files in the playlist folder of the output directory,
+// the playlists share the connections of dl
+func playlistDownloader(dl *downloader.Downloader, dirName string) *downloader.Downloader {
+	return dl.WithOutputDir(filepath.Join(dl.OutputDir, dirName))
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"errors"
+	"github.com/bit-twit/yt-dl-go/types"
+	"github.com/bit-twit/yt-dl-go/utils"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+const (
+	AppDirName     = "yt-dl-go"
+	ConfigFileName = "config.yaml"
+)
+
+// DefaultPath returns the config file location in the user config dir, eg: ~/.config/yt-dl-go/config.yaml
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, AppDirName, ConfigFileName), nil
+}
+
+// Load reads the config file on top of the default config.
+// A missing file is not an error, the defaults are returned.
+func Load(path string) (types.Config, error) {
+	c := types.DefaultConfig()
+	if path == "" {
+		return c, nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+
+	if err := yaml.Unmarshal(b, &c); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// ApplyEnv overrides the config with the values set in env vars
+func ApplyEnv(c *types.Config) {
+	c.ApiKey = utils.GetEnv("YOUTUBE_API_KEY", c.ApiKey)
+	c.ClientSecretPath = utils.GetEnv("YOUTUBE_CLIENT_SECRET", c.ClientSecretPath)
+	c.OutputDir = utils.GetEnv("YT_DL_GO_OUTPUT_DIR", c.OutputDir)
+	c.Proxy = utils.GetEnv("YT_DL_GO_PROXY", c.Proxy)
+	c.LimitRate = utils.GetEnv("YT_DL_GO_LIMIT_RATE", c.LimitRate)
+	c.Archive = utils.GetEnv("YT_DL_GO_ARCHIVE", c.Archive)
+	c.Converter.FFmpegPath = utils.GetEnv("YT_DL_GO_FFMPEG", c.Converter.FFmpegPath)
+	c.Concurrency.Connections = utils.GetEnvInt("YT_DL_GO_CONNECTIONS", c.Concurrency.Connections)
+}
+
+// Marshal encodes the config as YAML, with the API key, the proxy credentials and the server credentials masked
+func Marshal(c types.Config) ([]byte, error) {
+	switch {
+	case len(c.ApiKey) > 8:
+		c.ApiKey = c.ApiKey[:4] + "****"
+	case c.ApiKey != "":
+		c.ApiKey = "****"
+	}
+	c.Proxy = maskProxy(c.Proxy)
+	users := make([]types.UserConfig, len(c.Server.Users))
+	for i, u := range c.Server.Users {
+		if u.Token != "" {
+			u.Token = "****"
+		}
+		if u.Password != "" {
+			u.Password = "****"
+		}
+		users[i] = u
+	}
+	c.Server.Users = users
+	return yaml.Marshal(c)
+}
+
+// maskProxy hides the user and password of the proxy url, an invalid url is hidden whole
+func maskProxy(proxy string) string {
+	if proxy == "" {
+		return ""
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return "****"
+	}
+	if u.User != nil {
+		u.User = url.User("xxxxx")
+	}
+	return u.String()
+}
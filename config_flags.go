@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/bit-twit/yt-dl-go/api"
+	"github.com/bit-twit/yt-dl-go/config"
+	"github.com/bit-twit/yt-dl-go/downloader"
+	"github.com/bit-twit/yt-dl-go/types"
+	"log"
+	"os"
+)
+
+// configFlags binds the flags of a command to the config fields they override
+type configFlags struct {
+	fs      *flag.FlagSet
+	path    *string
+	setters map[string]func(*types.Config)
+}
+
+func registerConfigFlags(fs *flag.FlagSet) *configFlags {
+	defaultPath, _ := config.DefaultPath()
+	return &configFlags{
+		fs:      fs,
+		path:    fs.String("config", defaultPath, "The YAML config file."),
+		setters: map[string]func(*types.Config){},
+	}
+}
+
+func (f *configFlags) stringVar(name, value, usage string, set func(c *types.Config, v string)) {
+	p := f.fs.String(name, value, usage)
+	f.setters[name] = func(c *types.Config) { set(c, *p) }
+}
+
+func (f *configFlags) boolVar(name string, value bool, usage string, set func(c *types.Config, v bool)) {
+	p := f.fs.Bool(name, value, usage)
+	f.setters[name] = func(c *types.Config) { set(c, *p) }
+}
+
+func (f *configFlags) intVar(name string, value int, usage string, set func(c *types.Config, v int)) {
+	p := f.fs.Int(name, value, usage)
+	f.setters[name] = func(c *types.Config) { set(c, *p) }
+}
+
+// registerAPI adds the Youtube Data API credentials flags
+func (f *configFlags) registerAPI() *configFlags {
+	d := types.DefaultConfig()
+	f.stringVar("apiKey", d.ApiKey, "The API key from Google developer console.",
+		func(c *types.Config, v string) { c.ApiKey = v })
+	f.stringVar("clientSecret", d.ClientSecretPath, "The OAuth web api client secret file from Google developer console.",
+		func(c *types.Config, v string) { c.ClientSecretPath = v })
+	return f
+}
+
+// registerDownload adds the output directory, format preferences and converter flags
+func (f *configFlags) registerDownload() *configFlags {
+	d := types.DefaultConfig()
+	f.stringVar("outputDir", d.OutputDir, "The directory where downloaded videos are stored.",
+		func(c *types.Config, v string) { c.OutputDir = v })
+	f.boolVar("audioOnly", d.Format.AudioOnly, "Download only the audio stream and convert it to mp3 (requires ffmpeg).",
+		func(c *types.Config, v bool) { c.Format.AudioOnly = v })
+	f.intVar("itag", d.Format.Itag, "Download the format with this itag, see the formats command.",
+		func(c *types.Config, v int) { c.Format.Itag = v })
+	f.stringVar("mimeType", d.Format.MimeType, "Download the first format with this mime type.",
+		func(c *types.Config, v string) { c.Format.MimeType = v })
+	f.stringVar("quality", d.Format.Quality, "Download the first format with this quality, eg: hd720 or 360p.",
+		func(c *types.Config, v string) { c.Format.Quality = v })
+	f.stringVar("ffmpeg", d.Converter.FFmpegPath, "The ffmpeg binary used to convert audio.",
+		func(c *types.Config, v string) { c.Converter.FFmpegPath = v })
+	f.stringVar("audioBitrate", d.Converter.AudioBitrate, "The mp3 audio bitrate.",
+		func(c *types.Config, v string) { c.Converter.AudioBitrate = v })
+	return f
+}
+
+// registerArchive adds the download archive flag
+func (f *configFlags) registerArchive() *configFlags {
+	d := types.DefaultConfig()
+	f.stringVar("archive", d.Archive, "The file recording the downloaded videos, they are skipped by the next runs.",
+		func(c *types.Config, v string) { c.Archive = v })
+	return f
+}
+
+// registerNetwork adds the proxy, connections, chunks, downloads, retries and limitRate flags
+func (f *configFlags) registerNetwork() *configFlags {
+	d := types.DefaultConfig()
+	f.stringVar("proxy", d.Proxy, "The proxy URL, by default the HTTP_PROXY and HTTPS_PROXY env vars are used.",
+		func(c *types.Config, v string) { c.Proxy = v })
+	f.intVar("connections", d.Concurrency.Connections, "The maximum HTTP connections per host.",
+		func(c *types.Config, v int) { c.Concurrency.Connections = v })
+	f.intVar("chunks", d.Concurrency.Chunks, "The parallel byte range requests of a download, capped by -connections, 1 disables chunking.",
+		func(c *types.Config, v int) { c.Concurrency.Chunks = v })
+	f.stringVar("chunkSize", d.Concurrency.ChunkSize, "The size of the byte ranges downloaded in parallel, eg: 10M.",
+		func(c *types.Config, v string) { c.Concurrency.ChunkSize = v })
+	f.intVar("downloads", d.Concurrency.Downloads, "The videos downloaded at the same time, sharing the -connections.",
+		func(c *types.Config, v int) { c.Concurrency.Downloads = v })
+	f.intVar("retries", d.Retry.Attempts, "The requests sent at most when failing with a 5xx status or a network error, 1 disables the retries.",
+		func(c *types.Config, v int) { c.Retry.Attempts = v })
+	f.stringVar("limitRate", d.LimitRate, "The bandwidth shared by all the downloads, eg: 2M, or a schedule: \"unlimited 01:00-07:00, 500K otherwise\".",
+		func(c *types.Config, v string) { c.LimitRate = v })
+	return f
+}
+
+// load merges the config file, the env vars and the flags set on the command line, in increasing precedence
+func (f *configFlags) load() (types.Config, error) {
+	explicit := map[string]bool{}
+	f.fs.Visit(func(fl *flag.Flag) { explicit[fl.Name] = true })
+
+	if explicit["config"] {
+		if _, err := os.Stat(*f.path); err != nil {
+			return types.Config{}, configError(err)
+		}
+	}
+
+	c, err := config.Load(*f.path)
+	if err != nil {
+		return c, configError(fmt.Errorf("unable to load config %s: %w", *f.path, err))
+	}
+
+	config.ApplyEnv(&c)
+
+	for name, set := range f.setters {
+		if explicit[name] {
+			set(&c)
+		}
+	}
+	return c, nil
+}
+
+// newYoutubeAPI builds the API client from the config credentials
+func newYoutubeAPI(c types.Config) (*api.YoutubeAPI, error) {
+	if c.ApiKey == "" {
+		return nil, configError(errors.New("expected apiKey in config, YOUTUBE_API_KEY env or -apiKey param"))
+	}
+	if _, err := os.Stat(c.ClientSecretPath); err != nil {
+		return nil, configError(err)
+	}
+	return api.NewYoutubeAPI(c)
+}
+
+// newDownloader builds the downloader from the config, invalid settings are config errors
+func newDownloader(c types.Config) (*downloader.Downloader, error) {
+	dl, err := downloader.NewDownloaderFromConfig(c)
+	return dl, configError(err)
+}
+
+// sweepTempFiles removes the temp files left in the output directory by a previous run which crashed
+func sweepTempFiles(dl *downloader.Downloader) {
+	n, err := dl.SweepTempFiles()
+	if err != nil {
+		log.Printf("Unable to remove the temp files of %s: %v", dl.OutputDir, err)
+	} else if n > 0 {
+		log.Printf("Removed %d temp files left in %s", n, dl.OutputDir)
+	}
+}
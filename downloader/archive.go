@@ -0,0 +1,177 @@
+package downloader
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/bit-twit/yt-dl-go/types"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// archiveExtractor starts the archive lines, as in the yt-dlp archive files
+	archiveExtractor = "youtube"
+	// archiveFormatMP3 is the format recorded for the mp3 conversions
+	archiveFormatMP3 = "mp3"
+)
+
+// Archive records the downloaded videos, keyed by video id and format, so that the next runs skip them.
+// The file has a line per download, "youtube <id> <format>" where the format is an itag or mp3: the lines of
+// the yt-dlp archive files, without format, match every format of the video.
+type Archive struct {
+	path string
+
+	mu      sync.Mutex
+	formats map[string]map[string]bool // formats downloaded by video id, "" for any format
+}
+
+// OpenArchive loads the archive file, a missing file is an empty archive created by the first download
+func OpenArchive(path string) (*Archive, error) {
+	a := &Archive{path: path, formats: map[string]map[string]bool{}}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := a.read(f); err != nil {
+		return nil, fmt.Errorf("unable to read the archive %s: %w", path, err)
+	}
+	return a, nil
+}
+
+// read adds the youtube entries of r and returns the lines which were not in the archive yet,
+// the lines of other extractors and the malformed ones, such as the last line of an interrupted write, are ignored
+func (a *Archive) read(r io.Reader) ([]string, error) {
+	var added []string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 2 || len(fields) > 3 || fields[0] != archiveExtractor {
+			continue
+		}
+		id, format := fields[1], ""
+		if len(fields) == 3 {
+			format = fields[2]
+		}
+		if _, err := types.ExtractVideoID(id); err != nil {
+			continue
+		}
+		if a.add(id, format) {
+			added = append(added, archiveLine(id, format))
+		}
+	}
+	return added, s.Err()
+}
+
+// add records the format of the video in memory and tells if it was new
+func (a *Archive) add(id, format string) bool {
+	if a.formats[id][format] {
+		return false
+	}
+	if a.formats[id] == nil {
+		a.formats[id] = map[string]bool{}
+	}
+	a.formats[id][format] = true
+	return true
+}
+
+func archiveLine(id, format string) string {
+	if format == "" {
+		return archiveExtractor + " " + id
+	}
+	return archiveExtractor + " " + id + " " + format
+}
+
+// Has tells if the video was downloaded in the format, an itag or mp3, or imported without format
+func (a *Archive) Has(id, format string) bool {
+	if a == nil {
+		return false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.formats[id][""] || a.formats[id][format]
+}
+
+// Add records the download of the video in a format, an itag or mp3, and appends it to the archive file
+func (a *Archive) Add(id, format string) error {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.add(id, format) {
+		return nil
+	}
+	return a.append([]string{archiveLine(id, format)})
+}
+
+// Import adds the entries of an archive file, such as a yt-dlp --download-archive file, and returns how many
+// videos or formats were not in the archive yet
+func (a *Archive) Import(r io.Reader) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	added, err := a.read(r)
+	if err != nil {
+		return 0, err
+	}
+	if len(added) == 0 {
+		return 0, nil
+	}
+	return len(added), a.append(added)
+}
+
+// Len returns the number of videos in the archive
+func (a *Archive) Len() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.formats)
+}
+
+// append writes the lines at the end of the archive file, after the line cut by an interrupted write if any
+func (a *Archive) append(lines []string) error {
+	f, err := os.OpenFile(a.path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	data := strings.Join(lines, "\n") + "\n"
+	if info, statErr := f.Stat(); statErr == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			data = "\n" + data
+		}
+	}
+	_, err = f.WriteString(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// archiveFormat returns the format recorded for the preferences when it is known before fetching the video info:
+// mp3 in audio only mode, the itag when set, otherwise "" as the itag depends on the formats of the video
+func archiveFormat(f types.FormatConfig) string {
+	switch {
+	case f.AudioOnly:
+		return archiveFormatMP3
+	case f.Itag > 0:
+		return strconv.Itoa(f.Itag)
+	}
+	return ""
+}
+
+// archive adds the downloaded video to the archive, a failure is only logged since the file is there
+func (dl *Downloader) archive(v *types.Video, format string) {
+	if err := dl.Archive.Add(v.ID, format); err != nil {
+		log.Printf("Unable to add %s to the archive: %v", v.ID, err)
+	}
+}
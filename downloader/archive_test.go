@@ -0,0 +1,94 @@
+package downloader
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bit-twit/yt-dl-go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchive(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	path := filepath.Join(t.TempDir(), "archive.txt")
+
+	a, err := OpenArchive(path)
+	require.NoError(err)
+	assert.False(a.Has("BaW_jenozKc", "18"))
+	require.NoError(a.Add("BaW_jenozKc", "18"))
+	require.NoError(a.Add("BaW_jenozKc", "18"))
+	require.NoError(a.Add("QcHvzNBtlOw", "mp3"))
+
+	a, err = OpenArchive(path)
+	require.NoError(err)
+	assert.Equal(2, a.Len())
+	assert.True(a.Has("BaW_jenozKc", "18"))
+	assert.False(a.Has("BaW_jenozKc", "22"), "another format of the video")
+	assert.False(a.Has("BaW_jenozKc", "mp3"))
+	assert.True(a.Has("QcHvzNBtlOw", "mp3"))
+	assert.False(a.Has("QcHvzNBtlOw", "18"))
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(err)
+	assert.Equal("youtube BaW_jenozKc 18\nyoutube QcHvzNBtlOw mp3\n", string(b))
+
+	var none *Archive
+	assert.False(none.Has("BaW_jenozKc", "18"))
+	assert.NoError(none.Add("BaW_jenozKc", "18"))
+}
+
+func TestArchive_Import(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	path := filepath.Join(t.TempDir(), "archive.txt")
+	// the last line was cut by an interrupted write
+	require.NoError(ioutil.WriteFile(path, []byte("youtube BaW_jenozKc 18\nyoutube QcH"), 0644))
+
+	a, err := OpenArchive(path)
+	require.NoError(err)
+	ytdlp := "youtube BaW_jenozKc\nvimeo 123456789\nyoutube dQw4w9WgXcQ\n\nyoutube dQw4w9WgXcQ\nyoutube bad\n"
+	n, err := a.Import(strings.NewReader(ytdlp))
+	require.NoError(err)
+	assert.Equal(2, n)
+	for _, format := range []string{"18", "22", "mp3"} {
+		assert.True(a.Has("dQw4w9WgXcQ", format), "yt-dlp entries match every format: %s", format)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(err)
+	assert.Equal("youtube BaW_jenozKc 18\nyoutube QcH\nyoutube BaW_jenozKc\nyoutube dQw4w9WgXcQ\n", string(b))
+	a, err = OpenArchive(path)
+	require.NoError(err)
+	assert.Equal(2, a.Len())
+}
+
+func TestArchiveFormat(t *testing.T) {
+	assert.Equal(t, "mp3", archiveFormat(types.FormatConfig{AudioOnly: true, Itag: 22}))
+	assert.Equal(t, "22", archiveFormat(types.FormatConfig{Itag: 22}))
+	assert.Equal(t, "", archiveFormat(types.FormatConfig{MimeType: "video/webm", Quality: "hd720"}), "known once the info is fetched")
+}
+
+func TestDownloadContext_Archive(t *testing.T) {
+	s := &chunkServer{content: testContent()}
+	dl, video, format := newChunkDownloader(t, s)
+	archive, err := OpenArchive(filepath.Join(t.TempDir(), "archive.txt"))
+	require.NoError(t, err)
+	dl.Archive = archive
+
+	_, err = dl.DownloadContext(context.Background(), video, format, "")
+	require.NoError(t, err)
+	assert.True(t, archive.Has(video.ID, "18"))
+	requests := len(s.requestedRanges())
+
+	_, err = dl.DownloadContext(context.Background(), video, format, "")
+	assert.ErrorIs(t, err, types.ErrArchived)
+	assert.Len(t, s.requestedRanges(), requests, "archived video downloaded again")
+
+	mp3 := &types.Video{ID: video.ID, Formats: types.FormatList{{ItagNo: 140, URL: format.URL}}}
+	require.NoError(t, archive.Add(video.ID, "mp3"))
+	_, err = dl.DownloadMP3Context(context.Background(), mp3)
+	assert.ErrorIs(t, err, types.ErrArchived)
+}
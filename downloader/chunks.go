@@ -0,0 +1,253 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/bit-twit/yt-dl-go/types"
+	"github.com/bit-twit/yt-dl-go/utils"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// chunkStateSuffix is appended to the part file to name the list of the chunks already written
+const chunkStateSuffix = ".chunks"
+
+// errRangeIgnored is returned when a chunk request is answered with the whole stream
+var errRangeIgnored = errors.New("byte range ignored by the server")
+
+// chunks returns the number of parallel requests used to download the format
+func (dl *Downloader) chunks(format *types.Format) int {
+	size, chunkSize := format.Size(), dl.chunkSize()
+	if dl.Chunks <= 1 || size <= chunkSize {
+		return 1
+	}
+
+	n := dl.Chunks
+	if t, ok := dl.HTTPClient.Transport.(*http.Transport); ok && t.MaxConnsPerHost > 0 && n > t.MaxConnsPerHost {
+		n = t.MaxConnsPerHost
+	}
+	if share := dl.budget.share(); share > 0 && n > share {
+		n = share
+	}
+	if count := chunkCount(size, chunkSize); n > count {
+		n = count
+	}
+	return n
+}
+
+func (dl *Downloader) chunkSize() int64 {
+	if dl.ChunkSize > 0 {
+		return dl.ChunkSize
+	}
+	return default_chunk_size
+}
+
+func chunkCount(size, chunkSize int64) int {
+	return int((size + chunkSize - 1) / chunkSize)
+}
+
+// chunkState lists the chunks written to a part file, it is saved next to the part file to resume the download
+type chunkState struct {
+	Size      int64  `json:"size"`
+	ChunkSize int64  `json:"chunkSize"`
+	Done      []bool `json:"done"`
+}
+
+// bounds returns the first and last byte of a chunk
+func (s *chunkState) bounds(i int) (int64, int64) {
+	start := int64(i) * s.ChunkSize
+	end := start + s.ChunkSize - 1
+	if end >= s.Size {
+		end = s.Size - 1
+	}
+	return start, end
+}
+
+func (s *chunkState) save(partFile string) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(partFile+chunkStateSuffix, b, 0644)
+}
+
+// hasChunkState tells if the part file was written in chunks, it is then resumed in chunks even with a single
+// connection: its size doesn't tell what is downloaded
+func hasChunkState(out *os.File) bool {
+	_, err := os.Stat(out.Name() + chunkStateSuffix)
+	return err == nil
+}
+
+// loadChunkState returns the chunks already written to the part file. A part file without saved state
+// was written by a single request, its chunks are done up to its size.
+func loadChunkState(out *os.File, size, chunkSize int64) (*chunkState, error) {
+	state := &chunkState{Size: size, ChunkSize: chunkSize, Done: make([]bool, chunkCount(size, chunkSize))}
+
+	b, err := ioutil.ReadFile(out.Name() + chunkStateSuffix)
+	if err == nil {
+		var saved chunkState
+		if json.Unmarshal(b, &saved) == nil && saved.Size == size && saved.ChunkSize == chunkSize && len(saved.Done) == len(state.Done) {
+			return &saved, nil
+		}
+		// saved for another stream or chunk size, start over
+		return state, truncate(out)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	info, err := out.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > size {
+		return state, truncate(out)
+	}
+	for i := range state.Done {
+		_, end := state.bounds(i)
+		state.Done[i] = end < info.Size()
+	}
+	return state, nil
+}
+
+// downloadChunks downloads the missing chunks of the part file in parallel, or one after the other when a single
+// connection is available, and returns the size of the stream.
+// A failed chunk is requested again on its own, the chunks written are kept to resume an interrupted download.
+func (dl *Downloader) downloadChunks(ctx context.Context, v *types.Video, format *types.Format, src *streamURL, out *os.File) (int64, error) {
+	size := format.Size()
+	state, err := loadChunkState(out, size, dl.chunkSize())
+	if err != nil {
+		return 0, err
+	}
+	if _, err := src.get(ctx); err != nil {
+		return 0, err
+	}
+
+	var written int64
+	pending := make(chan int, len(state.Done))
+	for i, done := range state.Done {
+		if done {
+			start, end := state.bounds(i)
+			written += end - start + 1
+			continue
+		}
+		pending <- i
+	}
+	close(pending)
+	workers := dl.chunks(format)
+	log.Printf("Download %d chunks of %d bytes with %d connections, %d bytes already written",
+		len(pending), state.ChunkSize, workers, written)
+
+	chunkCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	p := newProgress(ctx, v, written, size)
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range pending {
+				start, end := state.bounds(i)
+				err := dl.downloadChunk(chunkCtx, src, out, start, end, p)
+
+				mu.Lock()
+				if err == nil {
+					// the chunk is on the disk before being listed, a crash can't leave a listed chunk unwritten
+					err = out.Sync()
+				}
+				if err == nil {
+					state.Done[i] = true
+					err = state.save(out.Name())
+				}
+				if err != nil && firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				mu.Unlock()
+				if err != nil {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if errors.Is(firstErr, errRangeIgnored) {
+		log.Printf("Byte ranges not supported, download with a single request")
+		os.Remove(out.Name() + chunkStateSuffix)
+		if err := truncate(out); err != nil {
+			return 0, err
+		}
+		return dl.downloadStream(ctx, v, format, src, out)
+	}
+	if firstErr != nil {
+		return 0, firstErr
+	}
+
+	if p != nil {
+		p.done()
+	}
+	os.Remove(out.Name() + chunkStateSuffix)
+	return size, nil
+}
+
+// downloadChunk writes the bytes start-end of the stream at their offset in the file,
+// a failed request is retried from the last byte written
+func (dl *Downloader) downloadChunk(ctx context.Context, src *streamURL, out *os.File, start, end int64, p *progress) error {
+	return dl.retry(ctx, fmt.Sprintf("bytes %d-%d", start, end), func() error {
+		n, err := dl.fetchRange(ctx, src, out, start, end, p)
+		start += n
+		return err
+	})
+}
+
+// fetchRange copies the bytes start-end of the stream at their offset in the file and returns the bytes written
+func (dl *Downloader) fetchRange(ctx context.Context, src *streamURL, out *os.File, start, end int64, p *progress) (int64, error) {
+	if err := dl.budget.acquire(ctx); err != nil {
+		return 0, err
+	}
+	defer dl.budget.release()
+	resp, err := dl.requestStream(ctx, src, fmt.Sprintf("bytes=%d-%d", start, end), http.StatusOK, http.StatusPartialContent)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, errRangeIgnored
+	}
+
+	var w io.Writer = &offsetWriter{f: out, offset: start}
+	if p != nil {
+		w = io.MultiWriter(w, p)
+	}
+	length := end - start + 1
+	n, err := io.Copy(w, dl.limitReader(ctx, io.LimitReader(resp.Body, length)))
+	downloadedBytes.Add(float64(n))
+	if err == nil && n < length {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// offsetWriter writes sequentially to a file from an offset
+type offsetWriter struct {
+	f      *os.File
+	offset int64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.f.WriteAt(p, w.offset)
+	w.offset += int64(n)
+	return n, err
+}
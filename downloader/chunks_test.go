@@ -0,0 +1,255 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bit-twit/yt-dl-go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chunkServer serves content with Range support, fail can answer a request instead
+type chunkServer struct {
+	content []byte
+	fail    func(w http.ResponseWriter, r *http.Request) bool
+
+	mu       sync.Mutex
+	ranges   []string
+	active   int
+	maxConns int
+}
+
+func (s *chunkServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.ranges = append(s.ranges, r.Header.Get("Range"))
+	s.active++
+	if s.active > s.maxConns {
+		s.maxConns = s.active
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.active--
+		s.mu.Unlock()
+	}()
+
+	// let the parallel requests overlap
+	time.Sleep(5 * time.Millisecond)
+	if s.fail != nil && s.fail(w, r) {
+		return
+	}
+	http.ServeContent(w, r, "stream.mp4", time.Time{}, bytes.NewReader(s.content))
+}
+
+func (s *chunkServer) requestedRanges() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ranges := append([]string(nil), s.ranges...)
+	sort.Strings(ranges)
+	return ranges
+}
+
+func newChunkDownloader(t *testing.T, s *chunkServer) (*Downloader, *types.Video, *types.Format) {
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+
+	dl := NewDownloader(t.TempDir())
+	dl.Chunks = 4
+	dl.ChunkSize = 100
+	dl.Retry = RetryPolicy{BaseDelay: time.Millisecond}
+	video := &types.Video{ID: "BaW_jenozKc", Title: "chunked"}
+	format := &types.Format{ItagNo: 18, URL: srv.URL, MimeType: "video/mp4", ContentLength: strconv.Itoa(len(s.content))}
+	return dl, video, format
+}
+
+func testContent() []byte {
+	var b bytes.Buffer
+	for i := 0; b.Len() < 1000; i++ {
+		fmt.Fprintf(&b, "%04d", i)
+	}
+	return b.Bytes()[:1000]
+}
+
+func TestDownloader_Chunks(t *testing.T) {
+	dl := NewDownloader("")
+	format := &types.Format{ContentLength: "1000"}
+
+	dl.Chunks, dl.ChunkSize = 4, 100
+	assert.Equal(t, 4, dl.chunks(format))
+
+	// capped by the transport connections
+	dl.HTTPClient.Transport = newTransport(2, nil)
+	assert.Equal(t, 2, dl.chunks(format))
+
+	// capped by the number of chunks
+	dl.ChunkSize = 600
+	assert.Equal(t, 2, dl.chunks(format))
+	dl.ChunkSize = 1000
+	assert.Equal(t, 1, dl.chunks(format))
+
+	// unknown size
+	assert.Equal(t, 1, dl.chunks(&types.Format{}))
+	dl.Chunks = 1
+	assert.Equal(t, 1, dl.chunks(format))
+}
+
+func TestDownloadContext_Chunked(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	s := &chunkServer{content: testContent()}
+	dl, video, format := newChunkDownloader(t, s)
+
+	var events []Progress
+	var mu sync.Mutex
+	ctx := WithProgress(context.Background(), func(p Progress) {
+		mu.Lock()
+		events = append(events, p)
+		mu.Unlock()
+	})
+	file, err := dl.DownloadContext(ctx, video, format, "chunked.mp4")
+	require.NoError(err)
+
+	downloaded, err := ioutil.ReadFile(file)
+	require.NoError(err)
+	assert.Equal(s.content, downloaded)
+	assert.NoFileExists(file + ".part")
+	assert.NoFileExists(file + ".part.chunks")
+
+	ranges := s.requestedRanges()
+	assert.Len(ranges, 10)
+	assert.Contains(ranges, "bytes=0-99")
+	assert.Contains(ranges, "bytes=900-999")
+	assert.LessOrEqual(s.maxConns, 4)
+	assert.Greater(s.maxConns, 1)
+
+	require.NotEmpty(events)
+	assert.Equal(int64(1000), events[len(events)-1].BytesWritten)
+}
+
+func TestDownloadContext_ChunkRetried(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	content := testContent()
+	var once500, onceTruncated sync.Once
+	s := &chunkServer{content: content}
+	s.fail = func(w http.ResponseWriter, r *http.Request) bool {
+		failed := false
+		switch r.Header.Get("Range") {
+		case "bytes=300-399":
+			once500.Do(func() {
+				w.WriteHeader(http.StatusInternalServerError)
+				failed = true
+			})
+		case "bytes=500-599":
+			// the connection drops after half the chunk
+			onceTruncated.Do(func() {
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes 500-599/%d", len(content)))
+				w.Header().Set("Content-Length", "100")
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write(content[500:550])
+				failed = true
+			})
+		}
+		return failed
+	}
+	dl, video, format := newChunkDownloader(t, s)
+
+	file, err := dl.DownloadContext(context.Background(), video, format, "retried.mp4")
+	require.NoError(err)
+	downloaded, err := ioutil.ReadFile(file)
+	require.NoError(err)
+	assert.Equal(content, downloaded)
+
+	ranges := s.requestedRanges()
+	assert.Len(ranges, 12)
+	// the truncated chunk is resumed from the last byte received
+	assert.Contains(ranges, "bytes=550-599")
+}
+
+func TestDownloadContext_ChunksResumed(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	content := testContent()
+	s := &chunkServer{content: content}
+	dl, video, format := newChunkDownloader(t, s)
+
+	part := filepath.Join(dl.OutputDir, "resumed.mp4.part")
+	written := make([]byte, len(content))
+	copy(written[200:300], content[200:300])
+	copy(written[900:], content[900:])
+	require.NoError(ioutil.WriteFile(part, written, 0644))
+	state := &chunkState{Size: 1000, ChunkSize: 100, Done: make([]bool, 10)}
+	state.Done[2], state.Done[9] = true, true
+	require.NoError(state.save(part))
+
+	file, err := dl.DownloadContext(context.Background(), video, format, "resumed.mp4")
+	require.NoError(err)
+	downloaded, err := ioutil.ReadFile(file)
+	require.NoError(err)
+	assert.Equal(content, downloaded)
+
+	ranges := s.requestedRanges()
+	assert.Len(ranges, 8)
+	assert.NotContains(ranges, "bytes=200-299")
+	assert.NotContains(ranges, "bytes=900-999")
+}
+
+func TestDownloadContext_ChunksFallback(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	content := testContent()
+	s := &chunkServer{content: content}
+	s.fail = func(w http.ResponseWriter, r *http.Request) bool {
+		// ranges not supported
+		w.Write(content)
+		return true
+	}
+	dl, video, format := newChunkDownloader(t, s)
+
+	file, err := dl.DownloadContext(context.Background(), video, format, "fallback.mp4")
+	require.NoError(err)
+	downloaded, err := ioutil.ReadFile(file)
+	require.NoError(err)
+	assert.Equal(content, downloaded)
+	assert.NoFileExists(file + ".part.chunks")
+}
+
+func TestDownloadContext_ChunksResumedWithOneConnection(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	content := testContent()
+	s := &chunkServer{content: content}
+	dl, video, format := newChunkDownloader(t, s)
+	dl.Chunks = 1
+
+	part := filepath.Join(dl.OutputDir, "resumed.mp4.part")
+	written := make([]byte, len(content))
+	copy(written[900:], content[900:])
+	require.NoError(ioutil.WriteFile(part, written, 0644))
+	state := &chunkState{Size: 1000, ChunkSize: 100, Done: make([]bool, 10)}
+	state.Done[9] = true
+	require.NoError(state.save(part))
+
+	file, err := dl.DownloadContext(context.Background(), video, format, "resumed.mp4")
+	require.NoError(err)
+	downloaded, err := ioutil.ReadFile(file)
+	require.NoError(err)
+	assert.Equal(content, downloaded)
+
+	ranges := s.requestedRanges()
+	assert.Len(ranges, 9, "the chunks written are kept")
+	assert.NotContains(ranges, "bytes=900-999")
+	assert.Equal(1, s.maxConns)
+	assert.NoFileExists(part + chunkStateSuffix)
+}
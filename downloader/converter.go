@@ -1,34 +1,96 @@
 package downloader
 
 import (
+	"context"
+	"github.com/bit-twit/yt-dl-go/utils"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
+const (
+	defaultFFmpegPath   = "ffmpeg"
+	defaultAudioBitrate = "128k" // medium quality
+)
+
+// Converter runs ffmpeg to convert downloaded streams
+type Converter struct {
+	FFmpegPath   string // defaults to ffmpeg from PATH
+	AudioBitrate string // defaults to 128k
+}
+
 func ConvertMP4aToMP3(fileName string) (string, error) {
+	return (&Converter{}).ConvertMP4aToMP3(fileName)
+}
+
+func (c *Converter) ConvertMP4aToMP3(fileName string) (string, error) {
+	return c.ConvertMP4aToMP3Context(context.Background(), fileName)
+}
+
+// ConvertMP4aToMP3Context kills ffmpeg when the context is done. The mp3 is written to a temp file renamed once
+// complete, the temp file is removed when the conversion fails.
+func (c *Converter) ConvertMP4aToMP3Context(ctx context.Context, fileName string) (string, error) {
 	destFile := changeExtension(fileName, "mp3")
+	tmp, err := utils.CreateTemp(destFile)
+	if err != nil {
+		return "", err
+	}
+	tmp.Close()
+	tmpFile := tmp.Name()
 
-	ffmpegVersionCmd := exec.Command("ffmpeg",
+	ffmpegPath := c.FFmpegPath
+	if ffmpegPath == "" {
+		ffmpegPath = defaultFFmpegPath
+	}
+	audioBitrate := c.AudioBitrate
+	if audioBitrate == "" {
+		audioBitrate = defaultAudioBitrate
+	}
+
+	ffmpegVersionCmd := exec.CommandContext(ctx, ffmpegPath,
 		"-y",
 		"-loglevel", "warning",
 		"-i", fileName,
 		"-acodec", "libmp3lame",
-		"-ab", "128k", // medium quality
-		destFile,
+		"-ab", audioBitrate,
+		"-f", "mp3",
+		tmpFile,
 	)
 	ffmpegVersionCmd.Stderr = os.Stderr
 	ffmpegVersionCmd.Stdout = os.Stdout
 
+	start := time.Now()
 	convErr := ffmpegVersionCmd.Run()
+	ffmpegDuration.Observe(time.Since(start).Seconds())
+	if ctx.Err() != nil {
+		os.Remove(tmpFile)
+		return "", ctx.Err()
+	}
 	if convErr != nil {
+		os.Remove(tmpFile)
+		ffmpegConversions.WithLabelValues("failure").Inc()
 		return "", convErr
 	}
+	ffmpegConversions.WithLabelValues("success").Inc()
 
+	if err := utils.CommitTemp(tmpFile, destFile, 0644); err != nil {
+		return "", err
+	}
 	return destFile, nil
 }
 
+// Available tells if the ffmpeg binary can be found
+func (c *Converter) Available() error {
+	ffmpegPath := c.FFmpegPath
+	if ffmpegPath == "" {
+		ffmpegPath = defaultFFmpegPath
+	}
+	_, err := exec.LookPath(ffmpegPath)
+	return err
+}
+
 func changeExtension(fileName string, newExt string) string {
 	ext := filepath.Ext(fileName)
 	if ext == "" {
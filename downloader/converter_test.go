@@ -3,6 +3,9 @@ package downloader
 import (
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"path/filepath"
 	"testing"
 )
 
@@ -27,3 +30,35 @@ func TestConvertMP4aToMP3(t *testing.T) {
 	assert.Equal("./test_data/Metallica/Frantic (Official Music Video).mp3", resFile)
 	assert.FileExists("./test_data/Metallica/Frantic (Official Music Video).mp3")
 }
+
+// fakeFFmpeg writes a script standing for ffmpeg, it writes some bytes to its output file then exits with status
+func fakeFFmpeg(t *testing.T, status int) string {
+	path := filepath.Join(t.TempDir(), "ffmpeg")
+	script := fmt.Sprintf("#!/bin/sh\nfor last; do :; done\nprintf partial > \"$last\"\nexit %d\n", status)
+	require.NoError(t, ioutil.WriteFile(path, []byte(script), 0755))
+	return path
+}
+
+func TestConverter_Atomic(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "song.mp4a")
+	require.NoError(t, ioutil.WriteFile(input, []byte("audio"), 0644))
+
+	c := &Converter{FFmpegPath: fakeFFmpeg(t, 1)}
+	_, err := c.ConvertMP4aToMP3(input)
+	assert.Error(t, err)
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "partial mp3 left")
+
+	c = &Converter{FFmpegPath: fakeFFmpeg(t, 0)}
+	file, err := c.ConvertMP4aToMP3(input)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "song.mp3"), file)
+	b, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "partial", string(b))
+	entries, err = ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2, "temp file left")
+}
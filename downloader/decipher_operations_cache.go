@@ -1,11 +1,17 @@
 package downloader
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
 var (
 	_ DecipherOperationsCache = NewSimpleCache()
 )
 
+// defaultDecipherOpsCache is used by the downloaders created without cache
+var defaultDecipherOpsCache = NewSimpleCache()
+
 const defaultCacheExpiration = time.Minute * time.Duration(5)
 
 type DecipherOperationsCache interface {
@@ -13,7 +19,9 @@ type DecipherOperationsCache interface {
 	Set(video string, operations []DecipherOperation)
 }
 
+// SimpleCache keeps the operations of the last video, it is safe for concurrent use
 type SimpleCache struct {
+	mu         sync.Mutex
 	videoID    string
 	expiredAt  time.Time
 	operations []DecipherOperation
@@ -24,12 +32,14 @@ func NewSimpleCache() *SimpleCache {
 }
 
 // Get : get cache  when it has same video id and not expired
-func (s SimpleCache) Get(videoID string) []DecipherOperation {
+func (s *SimpleCache) Get(videoID string) []DecipherOperation {
 	return s.GetCacheBefore(videoID, time.Now())
 }
 
 // GetCacheBefore : can pass time for testing
-func (s SimpleCache) GetCacheBefore(videoID string, time time.Time) []DecipherOperation {
+func (s *SimpleCache) GetCacheBefore(videoID string, time time.Time) []DecipherOperation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if videoID == s.videoID && s.expiredAt.After(time) {
 		operations := make([]DecipherOperation, len(s.operations))
 		copy(operations, s.operations)
@@ -44,6 +54,8 @@ func (s *SimpleCache) Set(videoID string, operations []DecipherOperation) {
 }
 
 func (s *SimpleCache) setWithExpiredTime(videoID string, operations []DecipherOperation, time time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.videoID = videoID
 	s.operations = make([]DecipherOperation, len(operations))
 	copy(s.operations, operations)
@@ -1,6 +1,8 @@
 package downloader
 
 import (
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 )
@@ -66,3 +68,17 @@ func TestSimpleCache(t *testing.T) {
 		})
 	}
 }
+
+func TestSimpleCache_Concurrent(t *testing.T) {
+	s := NewSimpleCache()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			s.Set(id, []DecipherOperation{func(b []byte) []byte { return b }})
+			s.Get(id)
+		}(strconv.Itoa(i))
+	}
+	wg.Wait()
+}
@@ -0,0 +1,56 @@
+package downloader
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+)
+
+// destinations serialises the downloads writing the same file, whatever their downloader
+var destinations = &fileLocks{locks: map[string]*fileLock{}}
+
+// fileLocks are locks keyed by file path, removed once no download holds or waits for them
+type fileLocks struct {
+	mu    sync.Mutex
+	locks map[string]*fileLock
+}
+
+type fileLock struct {
+	held  chan struct{}
+	users int
+}
+
+// lock waits until no other download writes the file, or the context is done,
+// and returns the function releasing the file
+func (l *fileLocks) lock(ctx context.Context, file string) (func(), error) {
+	if abs, err := filepath.Abs(file); err == nil {
+		file = abs
+	}
+	l.mu.Lock()
+	fl := l.locks[file]
+	if fl == nil {
+		fl = &fileLock{held: make(chan struct{}, 1)}
+		l.locks[file] = fl
+	}
+	fl.users++
+	l.mu.Unlock()
+
+	select {
+	case fl.held <- struct{}{}:
+		return func() {
+			<-fl.held
+			l.done(file, fl)
+		}, nil
+	case <-ctx.Done():
+		l.done(file, fl)
+		return nil, ctx.Err()
+	}
+}
+
+func (l *fileLocks) done(file string, fl *fileLock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if fl.users--; fl.users == 0 {
+		delete(l.locks, file)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/bit-twit/yt-dl-go/types"
+	"github.com/bit-twit/yt-dl-go/utils"
 	"io"
 	"io/ioutil"
 	"log"
@@ -21,6 +22,11 @@ import (
 const (
 	default_api_timeout  = 60
 	max_http_connections = 5
+	default_chunks       = 4
+	default_chunk_size   = 10 << 20
+
+	// partSuffix is appended to the files being downloaded, they are renamed once complete
+	partSuffix = ".part"
 )
 
 // Downloader offers high level functions to download videos into files
@@ -29,29 +35,138 @@ type Downloader struct {
 	// If not set, http.DefaultClient will be used
 	HTTPClient *http.Client
 
-	// decipherOpsCache cache decipher operations
+	// decipherOpsCache cache decipher operations, the downloaders without one share defaultDecipherOpsCache
 	decipherOpsCache DecipherOperationsCache
 	OutputDir        string // optional directory to store the files
+
+	// Converter is used by DownloadMP3, if not set ffmpeg from PATH is used with default settings
+	Converter *Converter
+
+	// Chunks is the number of parallel byte range requests used to download the formats of known size,
+	// capped by the MaxConnsPerHost of the transport and by the share of the connections left to each running
+	// download. 0 or 1 downloads with a single request.
+	Chunks int
+	// ChunkSize is the size of the byte ranges, defaults to 10MiB
+	ChunkSize int64
+	// Downloads is the number of videos downloaded at the same time by DownloadAll, capped by the connection budget
+	Downloads int
+	// Retry controls how the requests failed with a 5xx status or a network error are sent again
+	Retry RetryPolicy
+	// RateLimit limits the bandwidth of all the downloads, unlimited when nil
+	RateLimit *RateLimiter
+	// Archive records the videos downloaded, the downloads of the formats already in it are skipped, disabled when nil
+	Archive *Archive
+
+	// budget shares the stream connections between the downloads running at the same time
+	budget *connBudget
 }
 
 func NewDownloader(outputDir string) *Downloader {
-	tr := &http.Transport{
-		MaxConnsPerHost:       max_http_connections,
-		MaxIdleConns:          max_http_connections,
-		IdleConnTimeout:       default_api_timeout * time.Second,
-		ResponseHeaderTimeout: default_api_timeout * time.Second,
-		TLSHandshakeTimeout:   default_api_timeout * time.Second,
-	}
-	client := &http.Client{Transport: tr}
+	client := &http.Client{Transport: newTransport(max_http_connections, http.ProxyFromEnvironment)}
 	return &Downloader{
 		HTTPClient:       client,
 		decipherOpsCache: &SimpleCache{},
 		OutputDir:        outputDir,
+		Chunks:           default_chunks,
+		ChunkSize:        default_chunk_size,
+		Downloads:        default_downloads,
+		budget:           newConnBudget(max_http_connections),
 	}
 
 }
 
-func (dl *Downloader) getOutputFile(v *types.Video, format *types.Format, outputFile string) (string, error) {
+// NewDownloaderFromConfig creates a downloader with the output, converter, proxy, concurrency, retry, rate limit and
+// archive settings of the config
+func NewDownloaderFromConfig(c types.Config) (*Downloader, error) {
+	proxy := http.ProxyFromEnvironment
+	if c.Proxy != "" {
+		proxyURL, err := url.Parse(c.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url: %w", err)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	maxConnections := c.Concurrency.Connections
+	if maxConnections <= 0 {
+		maxConnections = max_http_connections
+	}
+
+	dl := NewDownloader(c.OutputDir)
+	dl.HTTPClient.Transport = newTransport(maxConnections, proxy)
+	dl.budget = newConnBudget(maxConnections)
+	dl.Chunks = c.Concurrency.Chunks
+	dl.Downloads = c.Concurrency.Downloads
+	if c.Concurrency.ChunkSize != "" {
+		chunkSize, err := utils.ParseBytes(c.Concurrency.ChunkSize)
+		if err != nil || chunkSize <= 0 {
+			return nil, fmt.Errorf("invalid chunk size %q", c.Concurrency.ChunkSize)
+		}
+		dl.ChunkSize = chunkSize
+	}
+	dl.Retry.Attempts = c.Retry.Attempts
+	var err error
+	if dl.Retry.BaseDelay, err = parseRetryDelay(c.Retry.BaseDelay); err != nil {
+		return nil, err
+	}
+	if dl.Retry.MaxDelay, err = parseRetryDelay(c.Retry.MaxDelay); err != nil {
+		return nil, err
+	}
+	if c.LimitRate != "" {
+		schedule, err := ParseRateSchedule(c.LimitRate)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Limit rate to %s", schedule)
+		dl.RateLimit = NewRateLimiter(schedule)
+	}
+	if c.Archive != "" {
+		if dl.Archive, err = OpenArchive(c.Archive); err != nil {
+			return nil, err
+		}
+	}
+	dl.Converter = &Converter{
+		FFmpegPath:   c.Converter.FFmpegPath,
+		AudioBitrate: c.Converter.AudioBitrate,
+	}
+	return dl, nil
+}
+
+// parseRetryDelay parses a delay such as 1s or 500ms, 0 for the default when empty
+func parseRetryDelay(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid retry delay %q", s)
+	}
+	return d, nil
+}
+
+func newTransport(maxConnections int, proxy func(*http.Request) (*url.URL, error)) *http.Transport {
+	return &http.Transport{
+		Proxy:                 proxy,
+		MaxConnsPerHost:       maxConnections,
+		MaxIdleConns:          maxConnections,
+		IdleConnTimeout:       default_api_timeout * time.Second,
+		ResponseHeaderTimeout: default_api_timeout * time.Second,
+		TLSHandshakeTimeout:   default_api_timeout * time.Second,
+	}
+}
+
+// DownloadPlan describes what a download would do, see PlanDownload
+type DownloadPlan struct {
+	Video         *types.Video
+	Format        *types.Format
+	OutputFile    string // the file the stream is written to
+	FinalFile     string // the file left once the download is complete, after any conversion
+	EstimatedSize int64  // stream bytes from the format content length, 0 when unknown
+}
+
+// OutputPath returns the destination file of a format download, without touching the file system.
+// Without outputFile the path is built from the title, "Artist - Song" titles go to an Artist folder.
+func (dl *Downloader) OutputPath(v *types.Video, format *types.Format, outputFile string) string {
 	outputDir := dl.OutputDir
 	if outputFile == "" {
 		if strings.Index(v.Title, "-") > 0 {
@@ -65,60 +180,295 @@ func (dl *Downloader) getOutputFile(v *types.Video, format *types.Format, output
 	}
 
 	if outputDir != "" {
-		if err := os.MkdirAll(outputDir, 0o755); err != nil {
-			return "", err
-		}
 		outputFile = filepath.Join(outputDir, outputFile)
 	}
+	return outputFile
+}
 
+// getOutputFile returns the destination file of a format download and creates its directory
+func (dl *Downloader) getOutputFile(v *types.Video, format *types.Format, outputFile string) (string, error) {
+	outputFile = dl.OutputPath(v, format, outputFile)
+	if dir := filepath.Dir(outputFile); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return "", err
+		}
+	}
 	return outputFile, nil
 }
 
+// PlanDownload resolves what Download would write, without creating directories or files
+func (dl *Downloader) PlanDownload(v *types.Video, format *types.Format, outputFile string) *DownloadPlan {
+	destFile := dl.OutputPath(v, format, outputFile)
+	return &DownloadPlan{
+		Video:         v,
+		Format:        format,
+		OutputFile:    destFile,
+		FinalFile:     destFile,
+		EstimatedSize: format.Size(),
+	}
+}
+
+// PlanDownloadMP3 resolves what DownloadMP3 would write, without creating directories or files
+func (dl *Downloader) PlanDownloadMP3(v *types.Video) (*DownloadPlan, error) {
+	format := v.Formats.FindByItag(140)
+	if format == nil {
+		return nil, fmt.Errorf("%w: itag 140", types.ErrFormatNotFound)
+	}
+	plan := dl.PlanDownload(v, format, "")
+	plan.FinalFile = changeExtension(plan.OutputFile, "mp3")
+	return plan, nil
+}
+
 func (dl *Downloader) DownloadMP3(v *types.Video) (string, error) {
+	return dl.DownloadMP3Context(context.Background(), v)
+}
+
+// DownloadMP3Context downloads the mp4 audio stream and converts it to mp3, it stops when the context is done.
+// The ProgressListener of the context receives a single Finish, with the mp3 file, or Fail.
+// It returns ErrArchived when the mp3 of the video is in the archive of the downloader, and adds it once converted.
+func (dl *Downloader) DownloadMP3Context(ctx context.Context, v *types.Video) (mp3File string, err error) {
+	if dl.Archive.Has(v.ID, archiveFormatMP3) {
+		return "", fmt.Errorf("%w: %s mp3", types.ErrArchived, v.ID)
+	}
+	phase := PhaseDownloading
+	defer func() { reportResult(ctx, phase, v, mp3File, err) }()
+
 	// seems id tag 140 is mp4 audio
-	youtubeFile, err := dl.Download(v, v.Formats.FindByItag(140), "")
+	format := v.Formats.FindByItag(140)
+	if format == nil {
+		return "", fmt.Errorf("%w: itag 140", types.ErrFormatNotFound)
+	}
+	youtubeFile, err := dl.downloadFile(ctx, v, format, "")
 	if err != nil {
 		return "", err
 	}
-	return ConvertMP4aToMP3(youtubeFile)
+
+	converter := dl.Converter
+	if converter == nil {
+		converter = &Converter{}
+	}
+	phase = PhaseConverting
+	reportPhase(ctx, phase, v)
+	if mp3File, err = converter.ConvertMP4aToMP3Context(ctx, youtubeFile); err == nil {
+		dl.archive(v, archiveFormatMP3)
+	}
+	return mp3File, err
 }
 
 func (dl *Downloader) Download(v *types.Video, format *types.Format, outputFile string) (string, error) {
+	return dl.DownloadContext(context.Background(), v, format, outputFile)
+}
+
+// DownloadContext downloads the format into a file, it stops when the context is done.
+// The stream is written to "<file>.part", resumed with a Range request when the part file already exists,
+// and flushed to the disk then renamed to the file once its size matches the content length. An interrupted download keeps its
+// part file for the next call. The progress is reported to the ProgressListener of the context, see WithProgressListener.
+// The downloads running at the same time share the connections of the downloader, see DownloadAll, a download
+// waits for the one writing the same file.
+// It returns ErrArchived when the format of the video is in the archive of the downloader, and adds it once complete.
+func (dl *Downloader) DownloadContext(ctx context.Context, v *types.Video, format *types.Format, outputFile string) (file string, err error) {
+	itag := strconv.Itoa(format.ItagNo)
+	if dl.Archive.Has(v.ID, itag) {
+		return "", fmt.Errorf("%w: %s itag %s", types.ErrArchived, v.ID, itag)
+	}
+	defer func() { reportResult(ctx, PhaseDownloading, v, file, err) }()
+	if file, err = dl.downloadFile(ctx, v, format, outputFile); err == nil {
+		dl.archive(v, itag)
+	}
+	return file, err
+}
+
+// downloadFile is DownloadContext without the report of the result, left to the caller
+func (dl *Downloader) downloadFile(ctx context.Context, v *types.Video, format *types.Format, outputFile string) (string, error) {
+	defer dl.budget.start()()
+
 	destFile, err := dl.getOutputFile(v, format, outputFile)
 	if err != nil {
 		return "", err
 	}
+	// another download of the same file would write the same part file
+	unlock, err := destinations.lock(ctx, destFile)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
 
-	// Create output file
-	out, err := os.Create(destFile)
+	partFile := destFile + partSuffix
+	out, err := os.OpenFile(partFile, os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		return "", err
 	}
 	defer out.Close()
 
-	fmt.Printf("Download to file= %s \n", destFile)
+	log.Printf("Download to file= %s", destFile)
 
-	resp, err := dl.getStream(v, format)
+	var expected int64
+	src := newStreamURL(dl, v, format)
+	if dl.chunks(format) > 1 || (format.Size() > 0 && hasChunkState(out)) {
+		expected, err = dl.downloadChunks(ctx, v, format, src, out)
+	} else {
+		expected, err = dl.downloadStream(ctx, v, format, src, out)
+	}
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
 
-	_, err = io.Copy(out, resp.Body)
+	info, err := out.Stat()
 	if err != nil {
 		return "", err
 	}
+	// on the disk before the rename, a crash can't leave a truncated file at the destination
+	if err := out.Sync(); err != nil {
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		return "", err
+	}
+	if expected >= 0 && info.Size() != expected {
+		if info.Size() > expected {
+			// can't be resumed
+			os.Remove(partFile)
+		}
+		return "", fmt.Errorf("%w: %s has %d bytes, expected %d", types.ErrSizeMismatch, partFile, info.Size(), expected)
+	}
 
+	if err := os.Rename(partFile, destFile); err != nil {
+		return "", err
+	}
 	return destFile, nil
 }
 
-// GetVideoInfo fetches video metadata with a context
-func (dl *Downloader) GetVideoInfo(ctx context.Context, id string) (*types.Video, error) {
+// downloadStream downloads the part file with a single request, holding a connection of the budget.
+// A failed request is sent again, resumed from the bytes already written.
+func (dl *Downloader) downloadStream(ctx context.Context, v *types.Video, format *types.Format, src *streamURL, out *os.File) (int64, error) {
+	if err := dl.budget.acquire(ctx); err != nil {
+		return 0, err
+	}
+	defer dl.budget.release()
+
+	var expected int64
+	err := dl.retry(ctx, "download of "+v.ID, func() (err error) {
+		expected, err = dl.downloadPart(ctx, v, format, src, out)
+		return err
+	})
+	return expected, err
+}
+
+// downloadPart appends the stream to the part file from its current size, it returns the expected size of the
+// complete stream, -1 when unknown
+func (dl *Downloader) downloadPart(ctx context.Context, v *types.Video, format *types.Format, src *streamURL, out *os.File) (int64, error) {
+	if _, err := os.Stat(out.Name() + chunkStateSuffix); err == nil {
+		// written in chunks, its size doesn't tell what is downloaded
+		os.Remove(out.Name() + chunkStateSuffix)
+		if err := truncate(out); err != nil {
+			return 0, err
+		}
+	}
+	offset, err := out.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	expected := format.Size()
+	if expected > 0 && offset == expected {
+		return expected, nil
+	}
+	if expected > 0 && offset > expected {
+		// not the same stream, start over
+		if offset, err = 0, truncate(out); err != nil {
+			return 0, err
+		}
+	}
+
+	rangeHeader := ""
+	if offset > 0 {
+		log.Printf("Resume %s from byte %d", out.Name(), offset)
+		rangeHeader = fmt.Sprintf("bytes=%d-", offset)
+	}
+	resp, err := dl.requestStream(ctx, src, rangeHeader, http.StatusOK, http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if expected <= 0 {
+			expected = contentRangeSize(resp.Header.Get("Content-Range"))
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		if size := contentRangeSize(resp.Header.Get("Content-Range")); size == offset {
+			// the part file is already complete
+			return size, nil
+		}
+		resp.Body.Close()
+		if err := truncate(out); err != nil {
+			return 0, err
+		}
+		return dl.downloadPart(ctx, v, format, src, out)
+	default:
+		// the range was ignored, the whole stream is sent
+		if offset > 0 {
+			if offset, err = 0, truncate(out); err != nil {
+				return 0, err
+			}
+		}
+		if expected <= 0 {
+			expected = resp.ContentLength
+		}
+	}
+
+	var w io.Writer = out
+	p := newProgress(ctx, v, offset, expected)
+	if p != nil {
+		w = io.MultiWriter(out, p)
+	}
+	n, err := io.Copy(w, dl.limitReader(ctx, resp.Body))
+	downloadedBytes.Add(float64(n))
+	if err == nil && p != nil {
+		p.done()
+	}
+	if expected <= 0 {
+		expected = -1
+	}
+	return expected, err
+}
+
+func truncate(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.Seek(0, io.SeekStart)
+	return err
+}
+
+// contentRangeSize returns the complete size of a "bytes 0-99/1000" or "bytes */1000" Content-Range, -1 when unknown
+func contentRangeSize(contentRange string) int64 {
+	i := strings.LastIndex(contentRange, "/")
+	if i < 0 {
+		return -1
+	}
+	size, err := strconv.ParseInt(contentRange[i+1:], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return size
+}
+
+// GetVideoInfo fetches video metadata with a context, the video can be given by id or by youtube url
+func (dl *Downloader) GetVideoInfo(ctx context.Context, videoIDOrURL string) (*types.Video, error) {
+	id, err := types.ExtractVideoID(videoIDOrURL)
+	if err != nil {
+		return nil, err
+	}
+	reportPhase(ctx, PhaseFetchingInfo, &types.Video{ID: id})
 
 	// Circumvent age restriction to pretend access through googleapis.com
 	eurl := "https://youtube.googleapis.com/v/" + id
 	finalUrl := "https://youtube.com/get_video_info?video_id=" + id + "&eurl=" + eurl
-	fmt.Printf("GET video info : %s", finalUrl)
+	log.Printf("GET video info : %s", finalUrl)
 	body, err := dl.httpGetBodyBytes(ctx, finalUrl)
 	if err != nil {
 		return nil, err
@@ -133,7 +483,7 @@ func (dl *Downloader) GetVideoInfo(ctx context.Context, id string) (*types.Video
 	// If the uploader has disabled embedding the video on other sites, parse video page
 	if err == types.ErrNotPlayableInEmbed {
 		videoPageUrl := "https://www.youtube.com/watch?v=" + id
-		fmt.Printf("EMBED DISABLED, GET video page: %s", videoPageUrl)
+		log.Printf("EMBED DISABLED, GET video page: %s", videoPageUrl)
 		html, err := dl.httpGetBodyBytes(ctx, videoPageUrl)
 		if err != nil {
 			return nil, err
@@ -145,18 +495,22 @@ func (dl *Downloader) GetVideoInfo(ctx context.Context, id string) (*types.Video
 	return v, err
 }
 
-// GetStream returns the HTTP response for a specific format
-func (dl *Downloader) getStream(video *types.Video, format *types.Format) (*http.Response, error) {
-	url, err := dl.getStreamURL(video, format)
-	if err != nil {
-		return nil, err
-	}
-
-	return dl.httpGet(context.Background(), url)
+// OpenStream requests the stream of a format without the signed url leaving the downloader.
+// rangeHeader is passed as the Range header when not empty, the response status is then 206 Partial Content
+// or 416 Range Not Satisfiable. The caller must close the response body.
+// The request is retried on 5xx and network errors, an expired or rejected url is fetched again.
+func (dl *Downloader) OpenStream(ctx context.Context, video *types.Video, format *types.Format, rangeHeader string) (*http.Response, error) {
+	src := newStreamURL(dl, video, format)
+	var resp *http.Response
+	err := dl.retry(ctx, "stream of "+video.ID, func() (err error) {
+		resp, err = dl.requestStream(ctx, src, rangeHeader, http.StatusOK, http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable)
+		return err
+	})
+	return resp, err
 }
 
 // GetStreamURL returns the url for a specific format
-func (dl *Downloader) getStreamURL(video *types.Video, format *types.Format) (string, error) {
+func (dl *Downloader) getStreamURL(ctx context.Context, video *types.Video, format *types.Format) (string, error) {
 	if format.URL != "" {
 		return format.URL, nil
 	}
@@ -166,10 +520,10 @@ func (dl *Downloader) getStreamURL(video *types.Video, format *types.Format) (st
 		return "", types.ErrCipherNotFound
 	}
 
-	return dl.decipherURL(video.ID, cipher)
+	return dl.decipherURL(ctx, video.ID, cipher)
 }
 
-func (dl *Downloader) decipherURL(videoID string, cipher string) (string, error) {
+func (dl *Downloader) decipherURL(ctx context.Context, videoID string, cipher string) (string, error) {
 	queryParams, err := url.ParseQuery(cipher)
 	if err != nil {
 		return "", err
@@ -196,7 +550,7 @@ func (dl *Downloader) decipherURL(videoID string, cipher string) (string, error)
 		return a.join("")
 	*/
 
-	operations, err := dl.parseDecipherOpsWithCache(videoID)
+	operations, err := dl.parseDecipherOpsWithCache(ctx, videoID)
 	if err != nil {
 		return "", err
 	}
@@ -211,21 +565,24 @@ func (dl *Downloader) decipherURL(videoID string, cipher string) (string, error)
 	return decipheredURL, nil
 }
 
-func (dl *Downloader) parseDecipherOpsWithCache(videoID string) (operations []DecipherOperation, err error) {
-	if dl.decipherOpsCache == nil {
-		dl.decipherOpsCache = NewSimpleCache()
+func (dl *Downloader) parseDecipherOpsWithCache(ctx context.Context, videoID string) (operations []DecipherOperation, err error) {
+	cache := dl.decipherOpsCache
+	if cache == nil {
+		cache = defaultDecipherOpsCache
 	}
 
-	if ops := dl.decipherOpsCache.Get(videoID); ops != nil {
+	if ops := cache.Get(videoID); ops != nil {
+		decipherCacheRequests.WithLabelValues("hit").Inc()
 		return ops, nil
 	}
+	decipherCacheRequests.WithLabelValues("miss").Inc()
 
-	ops, err := dl.parseDecipherOps(videoID)
+	ops, err := dl.parseDecipherOps(ctx, videoID)
 	if err != nil {
 		return nil, err
 	}
 
-	dl.decipherOpsCache.Set(videoID, ops)
+	cache.Set(videoID, ops)
 	return ops, err
 }
 
@@ -260,9 +617,9 @@ var (
 	swapRegexp    = regexp.MustCompile(fmt.Sprintf("(?m)(?:^|,)(%s)%s", jsvarStr, swapStr))
 )
 
-func (dl *Downloader) parseDecipherOps(videoID string) (operations []DecipherOperation, err error) {
+func (dl *Downloader) parseDecipherOps(ctx context.Context, videoID string) (operations []DecipherOperation, err error) {
 	embedURL := fmt.Sprintf("https://youtube.com/embed/%s?hl=en", videoID)
-	embedBody, err := dl.httpGetBodyBytes(context.Background(), embedURL)
+	embedBody, err := dl.httpGetBodyBytes(ctx, embedURL)
 	if err != nil {
 		return nil, err
 	}
@@ -274,7 +631,7 @@ func (dl *Downloader) parseDecipherOps(videoID string) (operations []DecipherOpe
 		return nil, errors.New("unable to find basejs URL in playerConfig")
 	}
 
-	basejsBody, err := dl.httpGetBodyBytes(context.Background(), "https://youtube.com"+escapedBasejsURL)
+	basejsBody, err := dl.httpGetBodyBytes(ctx, "https://youtube.com"+escapedBasejsURL)
 	if err != nil {
 		return nil, err
 	}
@@ -322,33 +679,48 @@ func (dl *Downloader) parseDecipherOps(videoID string) (operations []DecipherOpe
 	return ops, nil
 }
 
-func (dl *Downloader) httpGet(ctx context.Context, url string) (resp *http.Response, err error) {
-	fmt.Printf("GET %s\n", url)
-
+func (dl *Downloader) httpGet(ctx context.Context, url string) (*http.Response, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
+	return dl.httpDo(req, http.StatusOK)
+}
 
-	resp, err = dl.HTTPClient.Do(req)
+// httpDo sends the request and returns an HttpError when the response status is not one of the expected ones
+func (dl *Downloader) httpDo(req *http.Request, expected ...int) (*http.Response, error) {
+	log.Printf("%s %s", req.Method, req.URL)
+
+	start := time.Now()
+	resp, err := dl.HTTPClient.Do(req)
+	httpRequestDuration.Observe(time.Since(start).Seconds())
 	if err != nil {
+		httpRequests.WithLabelValues("error").Inc()
 		return nil, err
 	}
+	httpRequests.WithLabelValues(strconv.Itoa(resp.StatusCode)).Inc()
 
-	if resp.StatusCode != http.StatusOK {
-		resp.Body.Close()
-		return nil, &types.HttpError{Status: strconv.Itoa(resp.StatusCode), Reason: "Unexpected status code"}
+	for _, status := range expected {
+		if resp.StatusCode == status {
+			return resp, nil
+		}
 	}
-
-	return
+	resp.Body.Close()
+	return nil, &types.HttpError{Status: strconv.Itoa(resp.StatusCode), Reason: "Unexpected status code"}
 }
 
+// httpGetBodyBytes reads the body of a GET, retried on 5xx and network errors
 func (dl *Downloader) httpGetBodyBytes(ctx context.Context, url string) ([]byte, error) {
-	resp, err := dl.httpGet(ctx, url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+	var body []byte
+	err := dl.retry(ctx, "GET "+url, func() error {
+		resp, err := dl.httpGet(ctx, url)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
 
-	return ioutil.ReadAll(resp.Body)
+		body, err = ioutil.ReadAll(resp.Body)
+		return err
+	})
+	return body, err
 }
@@ -1,11 +1,18 @@
 package downloader
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
 	"testing"
 	"time"
 
+	"github.com/bit-twit/yt-dl-go/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -70,3 +77,150 @@ func TestDownload_MP3(t *testing.T) {
 		fmt.Printf("Result file : %s \n", resultFile)
 	}
 }
+
+func TestDownloadContext_CancelKeepsPartFile(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	started := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial content"))
+		w.(http.Flusher).Flush()
+		close(started)
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	dl := NewDownloader(t.TempDir())
+	video := &types.Video{ID: "BaW_jenozKc", Title: "partial"}
+	format := &types.Format{ItagNo: 18, URL: srv.URL, MimeType: "video/mp4"}
+
+	file, err := dl.DownloadContext(ctx, video, format, "partial.mp4")
+	require.ErrorIs(err, context.Canceled)
+	assert.Empty(file)
+	assert.NoFileExists(filepath.Join(dl.OutputDir, "partial.mp4"))
+	assert.FileExists(filepath.Join(dl.OutputDir, "partial.mp4.part"))
+}
+
+// newStreamServer serves content with Range support and records the Range headers received
+func newStreamServer(t *testing.T, content []byte) (*httptest.Server, *[]string) {
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "stream.mp4", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &ranges
+}
+
+func TestDownloadContext_Resume(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	srv, ranges := newStreamServer(t, content)
+
+	dl := NewDownloader(t.TempDir())
+	video := &types.Video{ID: "BaW_jenozKc", Title: "resumed"}
+	format := &types.Format{ItagNo: 18, URL: srv.URL, MimeType: "video/mp4", ContentLength: strconv.Itoa(len(content))}
+	dest := filepath.Join(dl.OutputDir, "resumed.mp4")
+	require.NoError(ioutil.WriteFile(dest+".part", content[:400], 0644))
+
+	var events []Progress
+	ctx := WithProgress(context.Background(), func(p Progress) { events = append(events, p) })
+	file, err := dl.DownloadContext(ctx, video, format, "resumed.mp4")
+	require.NoError(err)
+	assert.Equal(dest, file)
+	assert.Equal([]string{"bytes=400-"}, *ranges)
+
+	downloaded, err := ioutil.ReadFile(dest)
+	require.NoError(err)
+	assert.Equal(content, downloaded)
+	assert.NoFileExists(dest + ".part")
+
+	require.NotEmpty(events)
+	assert.Equal(int64(len(content)), events[len(events)-1].BytesWritten)
+
+	// a complete part file is renamed without request
+	require.NoError(ioutil.WriteFile(dest+".part", content, 0644))
+	_, err = dl.DownloadContext(context.Background(), video, format, "resumed.mp4")
+	require.NoError(err)
+	assert.Len(*ranges, 1)
+}
+
+func TestDownloadContext_RangeIgnored(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content)
+	}))
+	defer srv.Close()
+
+	dl := NewDownloader(t.TempDir())
+	video := &types.Video{ID: "BaW_jenozKc", Title: "restarted"}
+	format := &types.Format{ItagNo: 18, URL: srv.URL, MimeType: "video/mp4"}
+	dest := filepath.Join(dl.OutputDir, "restarted.mp4")
+	require.NoError(ioutil.WriteFile(dest+".part", []byte("stale"), 0644))
+
+	_, err := dl.DownloadContext(context.Background(), video, format, "restarted.mp4")
+	require.NoError(err)
+	downloaded, err := ioutil.ReadFile(dest)
+	require.NoError(err)
+	assert.Equal(content, downloaded)
+}
+
+func TestDownloadContext_SizeMismatch(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	srv, _ := newStreamServer(t, content)
+
+	dl := NewDownloader(t.TempDir())
+	video := &types.Video{ID: "BaW_jenozKc", Title: "truncated"}
+	format := &types.Format{ItagNo: 18, URL: srv.URL, MimeType: "video/mp4", ContentLength: "2000"}
+
+	_, err := dl.DownloadContext(context.Background(), video, format, "truncated.mp4")
+	require.ErrorIs(err, types.ErrSizeMismatch)
+	assert.NoFileExists(filepath.Join(dl.OutputDir, "truncated.mp4"))
+	// smaller than expected, kept to be resumed
+	assert.FileExists(filepath.Join(dl.OutputDir, "truncated.mp4.part"))
+}
+
+func TestDownloader_PlanDownload(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	outputDir := filepath.Join(t.TempDir(), "videos")
+	dl := NewDownloader(outputDir)
+	video := &types.Video{
+		ID:    "QcHvzNBtlOw",
+		Title: "Metallica - Frantic (Official Music Video)",
+		Formats: types.FormatList{
+			{ItagNo: 18, MimeType: `video/mp4; codecs="avc1.42001E, mp4a.40.2"`, ContentLength: "1048576"},
+			{ItagNo: 140, MimeType: `audio/mp4; codecs="mp4a.40.2"`, ContentLength: "4000000"},
+		},
+	}
+
+	plan := dl.PlanDownload(video, video.Formats.FindByItag(18), "")
+	assert.Equal(filepath.Join(outputDir, "Metallica", "Frantic (Official Music Video).mp4"), plan.OutputFile)
+	assert.Equal(plan.OutputFile, plan.FinalFile)
+	assert.Equal(int64(1048576), plan.EstimatedSize)
+
+	mp3Plan, err := dl.PlanDownloadMP3(video)
+	require.NoError(err)
+	assert.Equal(filepath.Join(outputDir, "Metallica", "Frantic (Official Music Video).mp4a"), mp3Plan.OutputFile)
+	assert.Equal(filepath.Join(outputDir, "Metallica", "Frantic (Official Music Video).mp3"), mp3Plan.FinalFile)
+	assert.Equal(int64(4000000), mp3Plan.EstimatedSize)
+
+	// planning has no side effect on the file system
+	assert.NoDirExists(outputDir)
+
+	file, err := dl.getOutputFile(video, video.Formats.FindByItag(18), "")
+	require.NoError(err)
+	assert.Equal(plan.OutputFile, file)
+	assert.DirExists(filepath.Join(outputDir, "Metallica"))
+}
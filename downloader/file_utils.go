@@ -1,10 +1,25 @@
 package downloader
 
 import (
+	"github.com/bit-twit/yt-dl-go/utils"
 	"regexp"
 	"strings"
+	"time"
 )
 
+// orphanTempAge keeps the temp files modified recently, they may be written by another running download
+const orphanTempAge = time.Hour
+
+// SweepTempFiles removes the temp files left in the output directory and its subdirectories by interrupted
+// conversions and writes, it returns the number of files removed. The .part files are kept to resume their downloads.
+func (dl *Downloader) SweepTempFiles() (int, error) {
+	dir := dl.OutputDir
+	if dir == "" {
+		dir = "."
+	}
+	return utils.SweepTempFiles(dir, orphanTempAge)
+}
+
 // Rely on hardcoded canonical mime types, as the ones provided by Go aren't exhaustive [1].
 // This seems to be a recurring problem for youtube downloaders, see [2].
 // The implementation is based on mozilla's list [3], IANA [4] and Youtube's support [5].
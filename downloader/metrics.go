@@ -0,0 +1,45 @@
+package downloader
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "yt_dl_go_http_requests_total",
+		Help: "HTTP requests made to youtube by status code, error when no response was received.",
+	}, []string{"status"})
+	httpRequestDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "yt_dl_go_http_request_duration_seconds",
+		Help:    "Time to receive the response headers of the HTTP requests made to youtube.",
+		Buckets: prometheus.DefBuckets,
+	})
+	downloadedBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "yt_dl_go_downloaded_bytes_total",
+		Help: "Bytes of the video streams written to files.",
+	})
+	decipherCacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "yt_dl_go_decipher_cache_requests_total",
+		Help: "Lookups of the decipher operations cache by result: hit or miss.",
+	}, []string{"result"})
+	ffmpegDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "yt_dl_go_ffmpeg_duration_seconds",
+		Help:    "Duration of the ffmpeg conversions.",
+		Buckets: []float64{1, 2.5, 5, 10, 30, 60, 120, 300},
+	})
+	ffmpegConversions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "yt_dl_go_ffmpeg_conversions_total",
+		Help: "ffmpeg conversions by result: success or failure.",
+	}, []string{"result"})
+)
+
+func init() {
+	// the results are exposed at 0 before the first lookup or conversion
+	for _, result := range []string{"hit", "miss"} {
+		decipherCacheRequests.WithLabelValues(result)
+	}
+	for _, result := range []string{"success", "failure"} {
+		ffmpegConversions.WithLabelValues(result)
+	}
+}
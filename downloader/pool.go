@@ -0,0 +1,224 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bit-twit/yt-dl-go/types"
+	"strconv"
+	"sync"
+)
+
+const default_downloads = 2
+
+// DownloadOptions select what DownloadAll does for every video
+type DownloadOptions struct {
+	Format types.FormatConfig
+	DryRun bool // only plan the downloads, see PlanDownload
+	// Progress receives the events of every video, in addition to the listener of the context
+	Progress ProgressListener
+}
+
+// DownloadResult is the outcome of a video given to DownloadAll
+type DownloadResult struct {
+	Index int // position of the video in the list given to DownloadAll
+	Video types.Video
+	File  string
+	Plan  *DownloadPlan // set instead of File in dry-run mode
+	// Skipped is set when the video was found in the archive of the downloader, nothing is downloaded
+	Skipped bool
+	// Duplicate is set with Skipped when the video was given earlier in the list
+	Duplicate bool
+	Err       error
+}
+
+// DownloadAll downloads the videos with Downloads workers and sends a result per video on the returned channel.
+// The videos are fetched by id, their playlist and title are kept in the result. The videos found in the archive
+// of the downloader are skipped, the downloaded ones are added to it. A video given twice, by id or url, is only
+// downloaded once and its next entries are skipped as duplicates. Once the context is done the
+// remaining videos are skipped, the channel is closed when the started downloads have returned.
+func (dl *Downloader) DownloadAll(ctx context.Context, videos []types.Video, opts DownloadOptions) <-chan DownloadResult {
+	if opts.Progress != nil {
+		ctx = WithProgressListener(ctx, opts.Progress)
+	}
+	results := make(chan DownloadResult, len(videos))
+	pending := make(chan int, len(videos))
+	seen := map[string]bool{}
+	for i, v := range videos {
+		id := v.ID
+		if extracted, err := types.ExtractVideoID(id); err == nil {
+			id = extracted
+		}
+		if seen[id] {
+			results <- DownloadResult{Index: i, Video: types.Video{ID: id, Title: v.Title, PlaylistID: v.PlaylistID},
+				Skipped: true, Duplicate: true}
+			continue
+		}
+		seen[id] = true
+		pending <- i
+	}
+	close(pending)
+
+	var wg sync.WaitGroup
+	for w := 0; w < dl.downloads(len(videos)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range pending {
+				if ctx.Err() != nil {
+					return
+				}
+				r := dl.downloadVideo(ctx, videos[i].ID, opts)
+				r.Index = i
+				r.Video.PlaylistID = videos[i].PlaylistID
+				if r.Video.Title == "" {
+					r.Video.Title = videos[i].Title
+				}
+				results <- r
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+// downloads returns the number of workers used by DownloadAll, capped by the connection budget
+func (dl *Downloader) downloads(videos int) int {
+	n := dl.Downloads
+	if n <= 0 {
+		n = 1
+	}
+	if dl.budget != nil && n > dl.budget.size() {
+		n = dl.budget.size()
+	}
+	if n > videos {
+		n = videos
+	}
+	return n
+}
+
+// downloadVideo fetches the video info and downloads, or plans in dry-run mode, either the preferred format or the mp3 audio.
+// The videos in the archive are skipped, before fetching the info when the preferences tell the format.
+func (dl *Downloader) downloadVideo(ctx context.Context, input string, opts DownloadOptions) DownloadResult {
+	r := DownloadResult{Video: types.Video{ID: input}}
+	if id, err := types.ExtractVideoID(input); err == nil {
+		// the archive is keyed by video id, not by url
+		r.Video.ID = id
+	}
+	if format := archiveFormat(opts.Format); format != "" && dl.Archive.Has(r.Video.ID, format) {
+		r.Skipped = true
+		return r
+	}
+	v, err := dl.GetVideoInfo(ctx, r.Video.ID)
+	if err != nil {
+		r.Err = err
+		reportResult(ctx, PhaseFetchingInfo, &r.Video, "", err)
+		return r
+	}
+	r.Video = types.Video{ID: v.ID, Title: v.Title}
+
+	switch {
+	case opts.Format.AudioOnly && opts.DryRun:
+		r.Plan, r.Err = dl.PlanDownloadMP3(v)
+	case opts.Format.AudioOnly:
+		r.File, r.Err = dl.DownloadMP3Context(ctx, v)
+	default:
+		format := v.Formats.Select(opts.Format)
+		if format == nil {
+			r.Err = fmt.Errorf("%w: %+v", types.ErrFormatNotFound, opts.Format)
+			reportResult(ctx, PhaseDownloading, v, "", r.Err)
+		} else if dl.Archive.Has(v.ID, strconv.Itoa(format.ItagNo)) {
+			r.Skipped = true
+		} else if opts.DryRun {
+			r.Plan = dl.PlanDownload(v, format, "")
+		} else {
+			r.File, r.Err = dl.DownloadContext(ctx, v, format, "")
+		}
+	}
+	if errors.Is(r.Err, types.ErrArchived) {
+		// archived by another download of the same video
+		r.Skipped, r.Err = true, nil
+	}
+	return r
+}
+
+// WithOutputDir returns a copy of the downloader storing the files in another directory,
+// it shares the HTTP client and the connection budget of the downloader
+func (dl *Downloader) WithOutputDir(outputDir string) *Downloader {
+	c := *dl
+	c.OutputDir = outputDir
+	return &c
+}
+
+// connBudget limits the stream connections opened by all the downloads of a downloader,
+// the chunked downloads running at the same time get an equal share of it
+type connBudget struct {
+	slots chan struct{}
+
+	mu      sync.Mutex
+	running int
+}
+
+func newConnBudget(connections int) *connBudget {
+	if connections <= 0 {
+		connections = max_http_connections
+	}
+	return &connBudget{slots: make(chan struct{}, connections)}
+}
+
+func (b *connBudget) size() int {
+	return cap(b.slots)
+}
+
+// acquire waits for a free connection, it must be released once the response body is closed
+func (b *connBudget) acquire(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *connBudget) release() {
+	if b != nil {
+		<-b.slots
+	}
+}
+
+// start counts a running download until the returned func is called
+func (b *connBudget) start() func() {
+	if b == nil {
+		return func() {}
+	}
+	b.mu.Lock()
+	b.running++
+	b.mu.Unlock()
+	return func() {
+		b.mu.Lock()
+		b.running--
+		b.mu.Unlock()
+	}
+}
+
+// share returns the connections a running download may use, at least 1, 0 without budget
+func (b *connBudget) share() int {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.running <= 1 {
+		return b.size()
+	}
+	if n := b.size() / b.running; n > 1 {
+		return n
+	}
+	return 1
+}
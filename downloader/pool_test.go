@@ -0,0 +1,265 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/bit-twit/yt-dl-go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// videoInfoTransport answers the video info requests with a single format streamed from streamURL,
+// the other requests are sent to the network. The n parameter of the stream url counts the info requests.
+type videoInfoTransport struct {
+	streamURL string
+	size      int
+
+	mu    sync.Mutex
+	infos int
+}
+
+func (t *videoInfoTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Path != "/get_video_info" {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	id := req.URL.Query().Get("video_id")
+	t.mu.Lock()
+	t.infos++
+	n := t.infos
+	t.mu.Unlock()
+	playerResponse, _ := json.Marshal(map[string]interface{}{
+		"playabilityStatus": map[string]interface{}{"status": "OK"},
+		"videoDetails":      map[string]interface{}{"videoId": id, "title": "video " + id},
+		"streamingData": map[string]interface{}{
+			"expiresInSeconds": "21540",
+			"formats": []map[string]interface{}{{
+				"itag":          18,
+				"url":           t.streamURL + "?id=" + id + "&n=" + strconv.Itoa(n),
+				"mimeType":      `video/mp4; codecs="avc1.42001E, mp4a.40.2"`,
+				"contentLength": strconv.Itoa(t.size),
+			}},
+		},
+	})
+	body := url.Values{"status": {"ok"}, "player_response": {string(playerResponse)}}.Encode()
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+		Body:          ioutil.NopCloser(bytes.NewReader([]byte(body))),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+func TestDownloadAll(t *testing.T) {
+	s := &chunkServer{content: testContent()}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+
+	dl := NewDownloader(t.TempDir())
+	dl.HTTPClient = &http.Client{Transport: &videoInfoTransport{streamURL: srv.URL, size: len(s.content)}}
+	dl.ChunkSize = 100
+	dl.Downloads = 3
+	dl.budget = newConnBudget(4)
+
+	videos := []types.Video{
+		{ID: "BaW_jenozKc", PlaylistID: "PL1"},
+		{ID: "QcHvzNBtlOw", PlaylistID: "PL1"},
+		{ID: "not a video", Title: "broken"},
+		{ID: "dQw4w9WgXcQ", PlaylistID: "PL1"},
+	}
+	var results []DownloadResult
+	for r := range dl.DownloadAll(context.Background(), videos, DownloadOptions{}) {
+		results = append(results, r)
+	}
+	require.Len(t, results, len(videos))
+	sort.Slice(results, func(i, j int) bool { return results[i].Index < results[j].Index })
+
+	for i, r := range results {
+		assert.Equal(t, i, r.Index)
+		assert.Equal(t, videos[i].PlaylistID, r.Video.PlaylistID)
+		if i == 2 {
+			assert.Error(t, r.Err)
+			assert.Equal(t, "broken", r.Video.Title)
+			continue
+		}
+		require.NoError(t, r.Err)
+		assert.Equal(t, "video "+videos[i].ID, r.Video.Title)
+		b, err := ioutil.ReadFile(r.File)
+		require.NoError(t, err)
+		assert.Equal(t, s.content, b)
+	}
+	assert.LessOrEqual(t, s.maxConns, 4, "connections above the budget")
+}
+
+func TestDownloadAll_Canceled(t *testing.T) {
+	dl := NewDownloader(t.TempDir())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results := dl.DownloadAll(ctx, []types.Video{{ID: "BaW_jenozKc"}, {ID: "QcHvzNBtlOw"}}, DownloadOptions{})
+	_, ok := <-results
+	assert.False(t, ok, "no video should be downloaded once canceled")
+}
+
+func TestDownloadContext_SharedBudget(t *testing.T) {
+	s := &chunkServer{content: testContent()}
+	dl, video, format := newChunkDownloader(t, s)
+	dl.budget = newConnBudget(2)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			file, err := dl.DownloadContext(context.Background(), video, format, "shared"+strconv.Itoa(i)+".mp4")
+			if assert.NoError(t, err) {
+				b, _ := ioutil.ReadFile(file)
+				assert.Equal(t, s.content, b)
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, s.maxConns, 2, "connections above the budget")
+}
+
+func TestConnBudget_Share(t *testing.T) {
+	b := newConnBudget(5)
+	assert.Equal(t, 5, b.share())
+
+	end1 := b.start()
+	assert.Equal(t, 5, b.share())
+	end2 := b.start()
+	assert.Equal(t, 2, b.share())
+	var ends []func()
+	for i := 0; i < 6; i++ {
+		ends = append(ends, b.start())
+	}
+	assert.Equal(t, 1, b.share())
+
+	for _, end := range ends {
+		end()
+	}
+	end2()
+	assert.Equal(t, 5, b.share())
+	end1()
+
+	var none *connBudget
+	assert.Equal(t, 0, none.share())
+	assert.NoError(t, none.acquire(context.Background()))
+	none.release()
+}
+
+func TestDownloadAll_Archive(t *testing.T) {
+	s := &chunkServer{content: testContent()}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	info := &videoInfoTransport{streamURL: srv.URL, size: len(s.content)}
+
+	dl := NewDownloader(t.TempDir())
+	dl.HTTPClient = &http.Client{Transport: info}
+	archive, err := OpenArchive(filepath.Join(t.TempDir(), "archive.txt"))
+	require.NoError(t, err)
+	require.NoError(t, archive.Add("QcHvzNBtlOw", "18"))
+	require.NoError(t, archive.Add("dQw4w9WgXcQ", "22"))
+	dl.Archive = archive
+
+	download := func(videos []types.Video, f types.FormatConfig) []DownloadResult {
+		var results []DownloadResult
+		for r := range dl.DownloadAll(context.Background(), videos, DownloadOptions{Format: f}) {
+			results = append(results, r)
+		}
+		sort.Slice(results, func(i, j int) bool { return results[i].Index < results[j].Index })
+		return results
+	}
+
+	results := download([]types.Video{
+		{ID: "BaW_jenozKc"},
+		{ID: "https://youtu.be/QcHvzNBtlOw"},
+		{ID: "dQw4w9WgXcQ"},
+	}, types.FormatConfig{MimeType: "video/mp4"})
+	require.Len(t, results, 3)
+	require.NoError(t, results[0].Err)
+	assert.False(t, results[0].Skipped)
+	assert.True(t, results[1].Skipped, "the url of an archived video")
+	assert.Equal(t, "QcHvzNBtlOw", results[1].Video.ID)
+	assert.Equal(t, "video QcHvzNBtlOw", results[1].Video.Title)
+	require.NoError(t, results[2].Err)
+	assert.False(t, results[2].Skipped, "archived in another format")
+	assert.True(t, archive.Has("BaW_jenozKc", "18"))
+	assert.True(t, archive.Has("dQw4w9WgXcQ", "18"))
+	assert.Equal(t, 3, info.infos, "the format is only known from the info")
+
+	// with an itag the archived videos are skipped without fetching their info
+	results = download([]types.Video{{ID: "https://www.youtube.com/watch?v=BaW_jenozKc"}, {ID: "dQw4w9WgXcQ"}}, types.FormatConfig{Itag: 18})
+	for _, r := range results {
+		assert.True(t, r.Skipped, "%s downloaded again", r.Video.ID)
+	}
+	assert.Equal(t, 3, info.infos)
+}
+
+func TestDownloadAll_Duplicates(t *testing.T) {
+	s := &chunkServer{content: testContent()}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	info := &videoInfoTransport{streamURL: srv.URL, size: len(s.content)}
+
+	dl := NewDownloader(t.TempDir())
+	dl.HTTPClient = &http.Client{Transport: info}
+	dl.ChunkSize = 100
+
+	videos := []types.Video{{ID: "BaW_jenozKc"}, {ID: "https://youtu.be/BaW_jenozKc"}}
+	var results []DownloadResult
+	for r := range dl.DownloadAll(context.Background(), videos, DownloadOptions{}) {
+		results = append(results, r)
+	}
+	require.Len(t, results, 2)
+	sort.Slice(results, func(i, j int) bool { return results[i].Index < results[j].Index })
+	require.NoError(t, results[0].Err)
+	assert.False(t, results[0].Skipped)
+	assert.True(t, results[1].Skipped)
+	assert.True(t, results[1].Duplicate)
+	assert.Equal(t, "BaW_jenozKc", results[1].Video.ID)
+	assert.Equal(t, 1, info.infos)
+}
+
+func TestDownloadContext_SameDestination(t *testing.T) {
+	s := &chunkServer{content: testContent()}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+
+	dl := NewDownloader(t.TempDir())
+	dl.HTTPClient = &http.Client{Transport: &videoInfoTransport{streamURL: srv.URL, size: len(s.content)}}
+	dl.ChunkSize = 100
+	v, err := dl.GetVideoInfo(context.Background(), "BaW_jenozKc")
+	require.NoError(t, err)
+
+	// the second download waits for the first one instead of writing the same part file
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = dl.DownloadContext(context.Background(), v, v.Formats.FindByItag(18), "a.mp4")
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dl.OutputDir, "a.mp4"))
+	require.NoError(t, err)
+	assert.Equal(t, s.content, b)
+	assert.Empty(t, destinations.locks)
+}
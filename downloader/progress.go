@@ -1,17 +1,189 @@
 package downloader
 
+import (
+	"context"
+	"encoding/json"
+	"github.com/bit-twit/yt-dl-go/types"
+	"os"
+	"sync"
+	"time"
+)
+
+// progressInterval limits how often the bytes written are reported
+const progressInterval = 250 * time.Millisecond
+
+// Phase is the step of a download
+type Phase string
+
+const (
+	PhaseFetchingInfo Phase = "fetching_info"
+	PhaseDownloading  Phase = "downloading"
+	PhaseConverting   Phase = "converting"
+)
+
+// Progress is reported while a video is fetched, downloaded and converted
+type Progress struct {
+	Phase        Phase         `json:"phase"`
+	VideoID      string        `json:"videoId"`
+	Title        string        `json:"title,omitempty"` // empty while fetching the info
+	BytesWritten int64         `json:"bytesWritten"`
+	Total        int64         `json:"total"`   // 0 when unknown
+	Percent      float64       `json:"percent"` // 0 when the total is unknown
+	Speed        float64       `json:"speed"`   // bytes per second
+	ETA          time.Duration `json:"eta"`     // encoded in seconds, 0 when unknown
+}
+
+// MarshalJSON encodes the progress with its ETA in seconds
+func (p Progress) MarshalJSON() ([]byte, error) {
+	type progress Progress
+	return json.Marshal(struct {
+		progress
+		ETA float64 `json:"eta"`
+	}{progress(p), p.ETA.Seconds()})
+}
+
+// UnmarshalJSON decodes a progress with its ETA in seconds
+func (p *Progress) UnmarshalJSON(b []byte) error {
+	type progress Progress
+	var decoded struct {
+		progress
+		ETA float64 `json:"eta"`
+	}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return err
+	}
+	*p = Progress(decoded.progress)
+	p.ETA = time.Duration(decoded.ETA * float64(time.Second))
+	return nil
+}
+
+// ProgressListener receives the events of the downloads started with its context, see WithProgressListener.
+// The methods can be called by parallel downloads at the same time.
+type ProgressListener interface {
+	// Start is called when the stream starts being written, with the bytes already downloaded and the total
+	Start(p Progress)
+	// Progress is called when a video enters a phase and, at most every 250ms, with the bytes written
+	Progress(p Progress)
+	// Finish is called once the file of a download is complete
+	Finish(p Progress, file string)
+	// Fail is called when a video can't be downloaded
+	Fail(p Progress, err error)
+}
+
+// ProgressFunc receives the progress of the downloads started with its context, see WithProgress
+type ProgressFunc func(Progress)
+
+func (fn ProgressFunc) Start(p Progress)               { fn(p) }
+func (fn ProgressFunc) Progress(p Progress)            { fn(p) }
+func (fn ProgressFunc) Finish(p Progress, file string) { fn(p) }
+func (fn ProgressFunc) Fail(p Progress, err error)     {}
+
+type progressKey struct{}
+
+// WithProgress returns a context reporting the progress of the downloads started with it to fn
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return WithProgressListener(ctx, fn)
+}
+
+// WithProgressListener returns a context reporting the events of the downloads started with it to l
+func WithProgressListener(ctx context.Context, l ProgressListener) context.Context {
+	return context.WithValue(ctx, progressKey{}, l)
+}
+
+func progressListener(ctx context.Context) ProgressListener {
+	l, _ := ctx.Value(progressKey{}).(ProgressListener)
+	return l
+}
+
+// reportPhase reports the start of a phase to the progress listener of the context, if any
+func reportPhase(ctx context.Context, phase Phase, v *types.Video) {
+	if l := progressListener(ctx); l != nil {
+		l.Progress(Progress{Phase: phase, VideoID: v.ID, Title: v.Title})
+	}
+}
+
+// reportResult reports the end of a download, in the phase which completed or failed, to the progress listener
+// of the context, if any
+func reportResult(ctx context.Context, phase Phase, v *types.Video, file string, err error) {
+	l := progressListener(ctx)
+	if l == nil {
+		return
+	}
+	p := Progress{Phase: phase, VideoID: v.ID, Title: v.Title}
+	if err != nil {
+		l.Fail(p, err)
+		return
+	}
+	if info, err := os.Stat(file); err == nil {
+		p.BytesWritten, p.Total, p.Percent = info.Size(), info.Size(), 100
+	}
+	l.Finish(p, file)
+}
+
+// progress counts the bytes written through it and reports them to the progress listener of the context,
+// it can be shared by the parallel requests of a download
 type progress struct {
-	contentLength     float64
-	totalWrittenBytes float64
-	downloadLevel     float64
+	mu          sync.Mutex
+	listener    ProgressListener
+	current     Progress
+	resumedFrom int64 // bytes already downloaded before, not counted in the speed
+	start       time.Time
+	lastReport  time.Time
+}
+
+// newProgress reports the start of the download and returns nil when the context has no progress listener,
+// offset is the size of a resumed download
+func newProgress(ctx context.Context, v *types.Video, offset, contentLength int64) *progress {
+	l := progressListener(ctx)
+	if l == nil {
+		return nil
+	}
+	if contentLength < 0 {
+		contentLength = 0
+	}
+	p := &progress{
+		listener:    l,
+		current:     Progress{Phase: PhaseDownloading, VideoID: v.ID, Title: v.Title, BytesWritten: offset, Total: contentLength},
+		resumedFrom: offset,
+		start:       time.Now(),
+	}
+	start := p.current
+	if start.Total > 0 {
+		start.Percent = float64(start.BytesWritten) / float64(start.Total) * 100
+	}
+	l.Start(start)
+	return p
 }
 
 func (dl *progress) Write(p []byte) (n int, err error) {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
 	n = len(p)
-	dl.totalWrittenBytes = dl.totalWrittenBytes + float64(n)
-	currentPercent := (dl.totalWrittenBytes / dl.contentLength) * 100
-	if (dl.downloadLevel <= currentPercent) && (dl.downloadLevel < 100) {
-		dl.downloadLevel++
+	dl.current.BytesWritten += int64(n)
+	if now := time.Now(); now.Sub(dl.lastReport) >= progressInterval {
+		dl.report(now)
 	}
 	return
 }
+
+// done reports the final count of bytes written
+func (dl *progress) done() {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	dl.report(time.Now())
+}
+
+func (dl *progress) report(now time.Time) {
+	dl.lastReport = now
+	p := dl.current
+	if elapsed := now.Sub(dl.start).Seconds(); elapsed > 0 {
+		p.Speed = float64(p.BytesWritten-dl.resumedFrom) / elapsed
+	}
+	if p.Total > 0 {
+		p.Percent = float64(p.BytesWritten) / float64(p.Total) * 100
+		if p.Speed > 0 && p.BytesWritten < p.Total {
+			p.ETA = time.Duration(float64(p.Total-p.BytesWritten) / p.Speed * float64(time.Second))
+		}
+	}
+	dl.listener.Progress(p)
+}
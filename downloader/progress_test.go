@@ -0,0 +1,139 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bit-twit/yt-dl-go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingListener records the events received as "<event> <bytes>/<total>"
+type recordingListener struct {
+	mu     sync.Mutex
+	events []string
+	err    error
+}
+
+func (l *recordingListener) record(event string, p Progress) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event+" "+strconv.FormatInt(p.BytesWritten, 10)+"/"+strconv.FormatInt(p.Total, 10))
+}
+
+func (l *recordingListener) Start(p Progress)               { l.record("start", p) }
+func (l *recordingListener) Progress(p Progress)            { l.record(string(p.Phase), p) }
+func (l *recordingListener) Finish(p Progress, file string) { l.record("finish", p) }
+func (l *recordingListener) Fail(p Progress, err error) {
+	l.record("fail", p)
+	l.err = err
+}
+
+func TestProgress(t *testing.T) {
+	var events []Progress
+	ctx := WithProgress(context.Background(), func(p Progress) { events = append(events, p) })
+
+	p := newProgress(ctx, &types.Video{ID: "BaW_jenozKc"}, 0, 100)
+	require.NotNil(t, p)
+	p.start = time.Now().Add(-time.Second)
+
+	p.Write(make([]byte, 40))
+	p.Write(make([]byte, 10)) // throttled
+	p.done()
+
+	require.Len(t, events, 3)
+	assert.Equal(t, int64(0), events[0].BytesWritten, "start")
+	assert.Equal(t, int64(100), events[0].Total, "start")
+
+	assert.Equal(t, PhaseDownloading, events[1].Phase)
+	assert.Equal(t, int64(40), events[1].BytesWritten)
+	assert.InDelta(t, 40, events[1].Percent, 0.01)
+	assert.Greater(t, events[1].Speed, 0.0)
+	assert.Greater(t, int64(events[1].ETA), int64(0))
+
+	assert.Equal(t, int64(50), events[2].BytesWritten)
+	assert.InDelta(t, 50, events[2].Percent, 0.01)
+}
+
+func TestProgress_WithoutFunc(t *testing.T) {
+	v := &types.Video{ID: "BaW_jenozKc"}
+	assert.Nil(t, newProgress(context.Background(), v, 0, 100))
+	// no panic without progress func
+	reportPhase(context.Background(), PhaseConverting, v)
+	reportResult(context.Background(), PhaseDownloading, v, "", errors.New("failed"))
+}
+
+func TestProgressListener(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	srv, _ := newStreamServer(t, content)
+
+	dl := NewDownloader(t.TempDir())
+	video := &types.Video{ID: "BaW_jenozKc", Title: "listened"}
+	format := &types.Format{ItagNo: 18, URL: srv.URL, MimeType: "video/mp4", ContentLength: strconv.Itoa(len(content))}
+
+	l := &recordingListener{}
+	ctx := WithProgressListener(context.Background(), l)
+	_, err := dl.DownloadContext(ctx, video, format, "listened.mp4")
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(l.events), 3)
+	assert.Equal(t, "start 0/1000", l.events[0])
+	assert.Equal(t, "downloading 1000/1000", l.events[len(l.events)-2])
+	assert.Equal(t, "finish 1000/1000", l.events[len(l.events)-1])
+
+	l = &recordingListener{}
+	ctx = WithProgressListener(context.Background(), l)
+	format.ContentLength = "2000"
+	_, err = dl.DownloadContext(ctx, video, format, "truncated.mp4")
+	require.Error(t, err)
+	require.GreaterOrEqual(t, len(l.events), 3)
+	assert.Equal(t, "start 0/2000", l.events[0])
+	assert.Equal(t, "downloading 1000/2000", l.events[len(l.events)-2])
+	assert.Equal(t, "fail 0/0", l.events[len(l.events)-1])
+	assert.ErrorIs(t, l.err, types.ErrSizeMismatch)
+}
+
+func TestProgressListener_MP3(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	srv, _ := newStreamServer(t, content)
+
+	dl := NewDownloader(t.TempDir())
+	dl.Converter = &Converter{FFmpegPath: fakeFFmpeg(t, 0)}
+	video := &types.Video{ID: "BaW_jenozKc", Title: "listened", Formats: types.FormatList{
+		{ItagNo: 140, URL: srv.URL, MimeType: "audio/mp4", ContentLength: strconv.Itoa(len(content))},
+	}}
+
+	l := &recordingListener{}
+	_, err := dl.DownloadMP3Context(WithProgressListener(context.Background(), l), video)
+	require.NoError(t, err)
+	assert.Equal(t, "start 0/1000", l.events[0])
+	assert.Equal(t, []string{"converting 0/0", "finish 7/7"}, l.events[len(l.events)-2:], "finish once, with the mp3")
+
+	l = &recordingListener{}
+	dl.Converter = &Converter{FFmpegPath: fakeFFmpeg(t, 1)}
+	_, err = dl.DownloadMP3Context(WithProgressListener(context.Background(), l), video)
+	require.Error(t, err)
+	assert.Equal(t, []string{"converting 0/0", "fail 0/0"}, l.events[len(l.events)-2:])
+	assert.NotContains(t, l.events, "finish 1000/1000")
+}
+
+func TestProgress_JSON(t *testing.T) {
+	b, err := json.Marshal(Progress{Phase: PhaseDownloading, VideoID: "BaW_jenozKc", ETA: 1500 * time.Millisecond})
+	require.NoError(t, err)
+
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, 1.5, decoded["eta"])
+	assert.Equal(t, "downloading", decoded["phase"])
+
+	var p Progress
+	require.NoError(t, json.Unmarshal(b, &p))
+	assert.Equal(t, 1500*time.Millisecond, p.ETA)
+	assert.Equal(t, "BaW_jenozKc", p.VideoID)
+}
@@ -0,0 +1,219 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"github.com/bit-twit/yt-dl-go/utils"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// rateBurst is the share of a second of transfer that can be read at once after an idle period
+	rateBurst = 4
+	// rateReadSize caps the reads of a limited stream, so that the waits stay short and the schedule applies live
+	rateReadSize = 16 << 10
+)
+
+// RateSchedule is the bandwidth allowed by time of day, eg: "unlimited 01:00-07:00, 500K otherwise".
+// The rules are separated by commas, the first window containing the time applies and the rule without window,
+// or with "otherwise", applies the rest of the day. A rate is a size per second such as 500K or 2M, or unlimited.
+type RateSchedule struct {
+	Default int64 // bytes per second outside of the windows, 0 for unlimited
+	Windows []RateWindow
+}
+
+// RateWindow is a rate applied daily from Start to End, which can be after midnight
+type RateWindow struct {
+	Start, End time.Duration // since midnight
+	Rate       int64         // bytes per second, 0 for unlimited
+}
+
+// ParseRateSchedule parses a rate such as 2M, or a schedule such as "unlimited 01:00-07:00, 500K otherwise"
+func ParseRateSchedule(s string) (RateSchedule, error) {
+	var schedule RateSchedule
+	hasDefault := false
+	for _, rule := range strings.Split(s, ",") {
+		fields := strings.Fields(rule)
+		if len(fields) == 0 || len(fields) > 2 {
+			return RateSchedule{}, fmt.Errorf("invalid rate schedule %q: expected <rate> [<hh:mm>-<hh:mm>|otherwise]", s)
+		}
+		rate, err := parseRate(fields[0])
+		if err != nil {
+			return RateSchedule{}, fmt.Errorf("invalid rate schedule %q: %w", s, err)
+		}
+
+		if len(fields) == 1 || fields[1] == "otherwise" {
+			if hasDefault {
+				return RateSchedule{}, fmt.Errorf("invalid rate schedule %q: more than one rate without window", s)
+			}
+			schedule.Default, hasDefault = rate, true
+			continue
+		}
+		w, err := parseRateWindow(fields[1])
+		if err != nil {
+			return RateSchedule{}, fmt.Errorf("invalid rate schedule %q: %w", s, err)
+		}
+		w.Rate = rate
+		schedule.Windows = append(schedule.Windows, w)
+	}
+	return schedule, nil
+}
+
+func parseRate(s string) (int64, error) {
+	if s == "unlimited" {
+		return 0, nil
+	}
+	rate, err := utils.ParseBytes(s)
+	if err != nil || rate <= 0 {
+		return 0, fmt.Errorf("invalid rate %q", s)
+	}
+	return rate, nil
+}
+
+// parseRateWindow parses "01:00-07:00"
+func parseRateWindow(s string) (RateWindow, error) {
+	bounds := strings.Split(s, "-")
+	if len(bounds) != 2 {
+		return RateWindow{}, fmt.Errorf("invalid window %q", s)
+	}
+	var w RateWindow
+	for i, bound := range bounds {
+		t, err := time.Parse("15:04", bound)
+		if err != nil {
+			return RateWindow{}, fmt.Errorf("invalid window %q", s)
+		}
+		d := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+		if i == 0 {
+			w.Start = d
+		} else {
+			w.End = d
+		}
+	}
+	if w.Start == w.End {
+		return RateWindow{}, fmt.Errorf("empty window %q", s)
+	}
+	return w, nil
+}
+
+// RateAt returns the bytes per second allowed at the local time t, 0 for unlimited
+func (s RateSchedule) RateAt(t time.Time) int64 {
+	d := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	for _, w := range s.Windows {
+		if w.Start < w.End && d >= w.Start && d < w.End {
+			return w.Rate
+		}
+		if w.Start > w.End && (d >= w.Start || d < w.End) {
+			return w.Rate
+		}
+	}
+	return s.Default
+}
+
+func (s RateSchedule) String() string {
+	var rules []string
+	for _, w := range s.Windows {
+		rules = append(rules, fmt.Sprintf("%s %02d:%02d-%02d:%02d", formatRate(w.Rate),
+			int(w.Start.Hours()), int(w.Start.Minutes())%60, int(w.End.Hours()), int(w.End.Minutes())%60))
+	}
+	if len(rules) == 0 {
+		return formatRate(s.Default)
+	}
+	return strings.Join(append(rules, formatRate(s.Default)+" otherwise"), ", ")
+}
+
+func formatRate(rate int64) string {
+	if rate <= 0 {
+		return "unlimited"
+	}
+	return utils.FormatBytes(rate) + "/s"
+}
+
+// RateLimiter is a token bucket limiting the bytes read by all the downloads sharing it.
+// The rate of the schedule is checked on every read, a new window applies to the running downloads.
+type RateLimiter struct {
+	schedule RateSchedule
+
+	mu     sync.Mutex
+	tokens float64 // bytes which can be read without waiting, negative when the readers are waiting
+	last   time.Time
+
+	// replaced by the tests
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+func NewRateLimiter(schedule RateSchedule) *RateLimiter {
+	return &RateLimiter{schedule: schedule, now: time.Now, sleep: sleepContext}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// wait takes n bytes from the bucket and waits until they are available, it returns at once when unlimited
+func (l *RateLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := l.now()
+	rate := l.schedule.RateAt(now)
+	if rate <= 0 {
+		l.tokens, l.last = 0, time.Time{}
+		l.mu.Unlock()
+		return nil
+	}
+
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * float64(rate)
+	}
+	if burst := float64(rate) / rateBurst; l.tokens > burst {
+		l.tokens = burst
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / float64(rate) * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	return l.sleep(ctx, delay)
+}
+
+// limitReader returns r limited by the rate limiter of the downloader, r itself without limiter
+func (dl *Downloader) limitReader(ctx context.Context, r io.Reader) io.Reader {
+	if dl.RateLimit == nil {
+		return r
+	}
+	return &limitedReader{ctx: ctx, r: r, limiter: dl.RateLimit}
+}
+
+type limitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *RateLimiter
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if len(p) > rateReadSize {
+		p = p[:rateReadSize]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.wait(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
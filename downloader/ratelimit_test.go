@@ -0,0 +1,161 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bit-twit/yt-dl-go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRateSchedule(t *testing.T) {
+	s, err := ParseRateSchedule("2M")
+	require.NoError(t, err)
+	assert.Equal(t, RateSchedule{Default: 2 << 20}, s)
+	assert.Equal(t, "2.0MiB/s", s.String())
+
+	s, err = ParseRateSchedule("unlimited 01:00-07:00, 500K otherwise")
+	require.NoError(t, err)
+	assert.Equal(t, RateSchedule{
+		Default: 500 << 10,
+		Windows: []RateWindow{{Start: time.Hour, End: 7 * time.Hour, Rate: 0}},
+	}, s)
+	assert.Equal(t, "unlimited 01:00-07:00, 500.0KiB/s otherwise", s.String())
+
+	for _, invalid := range []string{"", "fast", "0", "2M 25:00-07:00", "2M 07:00-07:00", "2M, 1M", "2M 01:00", "2M 01:00-02:00 daily"} {
+		_, err := ParseRateSchedule(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestRateSchedule_RateAt(t *testing.T) {
+	s, err := ParseRateSchedule("unlimited 01:00-07:00, 1M 22:30-01:00, 500K otherwise")
+	require.NoError(t, err)
+
+	at := func(hhmm string) int64 {
+		tm, err := time.ParseInLocation("15:04", hhmm, time.Local)
+		require.NoError(t, err)
+		return s.RateAt(tm)
+	}
+	assert.Equal(t, int64(0), at("01:00"))
+	assert.Equal(t, int64(0), at("06:59"))
+	assert.Equal(t, int64(500<<10), at("07:00"))
+	assert.Equal(t, int64(500<<10), at("12:00"))
+	assert.Equal(t, int64(1<<20), at("22:30"))
+	assert.Equal(t, int64(1<<20), at("00:15"))
+}
+
+// fakeClock is advanced by the waits of a rate limiter instead of sleeping
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	return ctx.Err()
+}
+
+func newFakeLimiter(t *testing.T, schedule string, start time.Time) (*RateLimiter, *fakeClock) {
+	s, err := ParseRateSchedule(schedule)
+	require.NoError(t, err)
+	clock := &fakeClock{now: start}
+	l := NewRateLimiter(s)
+	l.now, l.sleep = clock.Now, clock.Sleep
+	return l, clock
+}
+
+func TestRateLimiter(t *testing.T) {
+	start := time.Date(2021, 5, 1, 12, 0, 0, 0, time.Local)
+	l, clock := newFakeLimiter(t, "1K", start)
+	dl := &Downloader{RateLimit: l}
+
+	n, err := io.Copy(ioutil.Discard, dl.limitReader(context.Background(), bytes.NewReader(make([]byte, 4096))))
+	require.NoError(t, err)
+	assert.Equal(t, int64(4096), n)
+	assert.InDelta(t, 4, clock.Now().Sub(start).Seconds(), 0.01)
+}
+
+func TestRateLimiter_ScheduleAppliesLive(t *testing.T) {
+	start := time.Date(2021, 5, 1, 0, 59, 58, 0, time.Local)
+	l, clock := newFakeLimiter(t, "unlimited 01:00-07:00, 1K otherwise", start)
+	dl := &Downloader{RateLimit: l}
+
+	r := dl.limitReader(context.Background(), bytes.NewReader(make([]byte, 100<<10)))
+	buf := make([]byte, 512)
+	for {
+		if _, err := r.Read(buf); err == io.EOF {
+			break
+		}
+	}
+	// limited until 01:00, the rest is read at once
+	elapsed := clock.Now().Sub(start)
+	assert.GreaterOrEqual(t, int64(elapsed), int64(2*time.Second))
+	assert.Less(t, int64(elapsed), int64(3*time.Second))
+}
+
+func TestRateLimiter_Canceled(t *testing.T) {
+	s, err := ParseRateSchedule("1K")
+	require.NoError(t, err)
+	dl := &Downloader{RateLimit: NewRateLimiter(s)}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = io.Copy(ioutil.Discard, dl.limitReader(ctx, bytes.NewReader(make([]byte, 4096))))
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestDownloadContext_RateLimitShared(t *testing.T) {
+	s := &chunkServer{content: bytes.Repeat(testContent(), 20)}
+	dl, video, format := newChunkDownloader(t, s)
+	dl.ChunkSize = 5000
+	// 20000 bytes at 20000/s, the burst of the first reads is 0
+	schedule, err := ParseRateSchedule("20000")
+	require.NoError(t, err)
+	dl.RateLimit = NewRateLimiter(schedule)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			file, err := dl.DownloadContext(context.Background(), video, format, "limited"+strconv.Itoa(i)+".mp4")
+			if assert.NoError(t, err) {
+				b, _ := ioutil.ReadFile(file)
+				assert.Equal(t, s.content, b)
+			}
+		}(i)
+	}
+	wg.Wait()
+	// 40000 bytes shared by both downloads and their chunks
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(1500*time.Millisecond))
+}
+
+func TestNewDownloaderFromConfig_LimitRate(t *testing.T) {
+	c := types.DefaultConfig()
+	c.LimitRate = "unlimited 01:00-07:00, 500K otherwise"
+	dl, err := NewDownloaderFromConfig(c)
+	require.NoError(t, err)
+	require.NotNil(t, dl.RateLimit)
+	assert.Equal(t, int64(500<<10), dl.RateLimit.schedule.Default)
+
+	c.LimitRate = "fast"
+	_, err = NewDownloaderFromConfig(c)
+	assert.Error(t, err)
+}
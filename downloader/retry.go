@@ -0,0 +1,92 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"github.com/bit-twit/yt-dl-go/types"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	default_retry_attempts   = 4
+	default_retry_base_delay = time.Second
+	default_retry_max_delay  = 30 * time.Second
+)
+
+// RetryPolicy controls how the requests failed with a 5xx status or a network error are sent again,
+// they are delayed with an exponential backoff and jitter
+type RetryPolicy struct {
+	Attempts  int           // requests sent at most, defaults to 4, 1 disables the retries
+	BaseDelay time.Duration // delay before the first retry, doubled for every next one, defaults to 1s
+	MaxDelay  time.Duration // longest delay between two attempts, defaults to 30s
+}
+
+func (p RetryPolicy) attempts() int {
+	if p.Attempts > 0 {
+		return p.Attempts
+	}
+	return default_retry_attempts
+}
+
+// delay returns the time to wait before the attempt, between half and all of the exponential backoff
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	base, max := p.BaseDelay, p.MaxDelay
+	if base <= 0 {
+		base = default_retry_base_delay
+	}
+	if max <= 0 {
+		max = default_retry_max_delay
+	}
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// retry calls fn until it succeeds, fails with an error which can't be retried or runs out of attempts
+func (dl *Downloader) retry(ctx context.Context, what string, fn func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil || ctx.Err() != nil || !retryable(err) {
+			return err
+		}
+		if attempt >= dl.Retry.attempts() {
+			return err
+		}
+		delay := dl.Retry.delay(attempt)
+		log.Printf("Retry %s in %s, attempt %d: %v", what, delay.Round(time.Millisecond), attempt+1, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// retryable tells if the error is a 5xx response, a network error or a stream cut before its end
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var httpErr *types.HttpError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode() >= http.StatusInternalServerError
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// isForbidden tells if the error is a 403 response, returned for expired or revoked stream urls
+func isForbidden(err error) bool {
+	var httpErr *types.HttpError
+	return errors.As(err, &httpErr) && httpErr.StatusCode() == http.StatusForbidden
+}
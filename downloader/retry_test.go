@@ -0,0 +1,203 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bit-twit/yt-dl-go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt, max := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 400 * time.Millisecond, 6: time.Second} {
+		for i := 0; i < 20; i++ {
+			d := p.delay(attempt)
+			assert.GreaterOrEqual(t, int64(d), int64(max/2), "attempt %d", attempt)
+			assert.LessOrEqual(t, int64(d), int64(max), "attempt %d", attempt)
+		}
+	}
+	assert.Equal(t, default_retry_attempts, RetryPolicy{}.attempts())
+}
+
+func TestRetryable(t *testing.T) {
+	assert.True(t, retryable(&types.HttpError{Status: "503"}))
+	assert.True(t, retryable(fmt.Errorf("bytes 0-99: %w", io.ErrUnexpectedEOF)))
+	assert.True(t, retryable(&netError{}))
+	assert.False(t, retryable(&types.HttpError{Status: "404"}))
+	assert.False(t, retryable(&types.HttpError{Status: "403"}))
+	assert.False(t, retryable(context.Canceled))
+	assert.False(t, retryable(types.ErrSizeMismatch))
+}
+
+// netError is a network error
+type netError struct{}
+
+func (e *netError) Error() string   { return "connection reset" }
+func (e *netError) Timeout() bool   { return false }
+func (e *netError) Temporary() bool { return true }
+
+func TestDownloader_RetryGivesUp(t *testing.T) {
+	dl := NewDownloader(t.TempDir())
+	dl.Retry = RetryPolicy{Attempts: 3, BaseDelay: time.Millisecond}
+
+	calls := 0
+	err := dl.retry(context.Background(), "test", func() error {
+		calls++
+		return &types.HttpError{Status: "500"}
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	err = dl.retry(context.Background(), "test", func() error {
+		calls++
+		return &types.HttpError{Status: "404"}
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls, "a 404 isn't retried")
+}
+
+func TestDownloadContext_Retried(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	var (
+		mu       sync.Mutex
+		requests []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Header.Get("Range"))
+		n := len(requests)
+		mu.Unlock()
+		switch n {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			// the connection drops in the middle of the stream
+			w.Header().Set("Content-Length", "1000")
+			w.Write(content[:400])
+		default:
+			http.ServeContent(w, r, "stream.mp4", time.Time{}, bytes.NewReader(content))
+		}
+	}))
+	defer srv.Close()
+
+	dl := NewDownloader(t.TempDir())
+	dl.Chunks = 1
+	dl.Retry = RetryPolicy{BaseDelay: time.Millisecond}
+	video := &types.Video{ID: "BaW_jenozKc", Title: "retried"}
+	format := &types.Format{ItagNo: 18, URL: srv.URL, MimeType: "video/mp4", ContentLength: "1000"}
+
+	file, err := dl.DownloadContext(context.Background(), video, format, "retried.mp4")
+	require.NoError(err)
+	downloaded, err := ioutil.ReadFile(file)
+	require.NoError(err)
+	assert.Equal(content, downloaded)
+	assert.Equal([]string{"", "", "bytes=400-"}, requests)
+}
+
+// expiringServer serves content with Range support for the stream url of the last info request,
+// the older urls, or all of them once revoked, are answered with 403
+type expiringServer struct {
+	content []byte
+	info    *videoInfoTransport
+	revoked bool
+
+	mu   sync.Mutex
+	urls []string
+}
+
+func (s *expiringServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.info.mu.Lock()
+	current := fmt.Sprint(s.info.infos)
+	s.info.mu.Unlock()
+
+	s.mu.Lock()
+	s.urls = append(s.urls, r.URL.Query().Get("n")+" "+r.Header.Get("Range"))
+	s.mu.Unlock()
+	if s.revoked || r.URL.Query().Get("n") != current {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	http.ServeContent(w, r, "stream.mp4", time.Time{}, bytes.NewReader(s.content))
+}
+
+func newExpiringDownloader(t *testing.T) (*Downloader, *expiringServer) {
+	s := &expiringServer{content: testContent()}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	s.info = &videoInfoTransport{streamURL: srv.URL, size: len(s.content)}
+
+	dl := NewDownloader(t.TempDir())
+	dl.HTTPClient = &http.Client{Transport: s.info}
+	dl.Retry = RetryPolicy{BaseDelay: time.Millisecond}
+	return dl, s
+}
+
+func TestDownloadContext_URLRefreshedOn403(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	dl, s := newExpiringDownloader(t)
+	dl.Chunks = 1
+
+	v, err := dl.GetVideoInfo(context.Background(), "BaW_jenozKc")
+	require.NoError(err)
+	format := v.Formats.FindByItag(18)
+	// the url is revoked once the first half is written
+	dest := dl.OutputPath(v, format, "")
+	require.NoError(ioutil.WriteFile(dest+partSuffix, s.content[:500], 0644))
+	_, err = dl.GetVideoInfo(context.Background(), "BaW_jenozKc")
+	require.NoError(err)
+
+	file, err := dl.DownloadContext(context.Background(), v, format, "")
+	require.NoError(err)
+	downloaded, err := ioutil.ReadFile(file)
+	require.NoError(err)
+	assert.Equal(s.content, downloaded)
+	assert.Equal([]string{"1 bytes=500-", "3 bytes=500-"}, s.urls)
+}
+
+func TestDownloadContext_ExpiredURLRefreshed(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	dl, s := newExpiringDownloader(t)
+	dl.ChunkSize = 100
+
+	v, err := dl.GetVideoInfo(context.Background(), "BaW_jenozKc")
+	require.NoError(err)
+	assert.False(v.ExpiresAt.IsZero())
+	v.ExpiresAt = time.Now().Add(-time.Minute)
+
+	file, err := dl.DownloadContext(context.Background(), v, v.Formats.FindByItag(18), "")
+	require.NoError(err)
+	downloaded, err := ioutil.ReadFile(file)
+	require.NoError(err)
+	assert.Equal(s.content, downloaded)
+	require.Len(s.urls, 10)
+	for _, u := range s.urls {
+		assert.Equal(byte('2'), u[0], "expired url requested: %s", u)
+	}
+}
+
+func TestStreamURL_RefreshLimit(t *testing.T) {
+	dl, s := newExpiringDownloader(t)
+	dl.Chunks = 1
+	s.revoked = true
+
+	v, err := dl.GetVideoInfo(context.Background(), "BaW_jenozKc")
+	require.NoError(t, err)
+	src := newStreamURL(dl, v, v.Formats.FindByItag(18))
+	_, err = dl.requestStream(context.Background(), src, "", http.StatusOK)
+	require.Error(t, err)
+	assert.Equal(t, maxURLRefreshes, src.refreshes)
+	assert.Len(t, s.urls, maxURLRefreshes+1)
+}
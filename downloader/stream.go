@@ -0,0 +1,174 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"github.com/bit-twit/yt-dl-go/types"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// maxURLRefreshes is how many times the stream url of a download is fetched again after a 403
+	maxURLRefreshes = 2
+	// streamExpiryMargin refreshes the stream urls a bit before they expire, so that a request isn't cut
+	streamExpiryMargin = 30 * time.Second
+)
+
+// streamURL is the signed url of a format, shared by the requests of a download. It is fetched again with the
+// video info when it expired or was rejected with a 403, the download then continues with the new url.
+type streamURL struct {
+	dl     *Downloader
+	video  *types.Video
+	format *types.Format
+
+	mu        sync.Mutex
+	url       string
+	expiresAt time.Time
+	refreshes int
+}
+
+func newStreamURL(dl *Downloader, video *types.Video, format *types.Format) *streamURL {
+	return &streamURL{dl: dl, video: video, format: format, expiresAt: video.ExpiresAt}
+}
+
+// get returns the url, refreshed first when it expired
+func (s *streamURL) get(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.expiresAt.IsZero() && time.Now().After(s.expiresAt.Add(-streamExpiryMargin)) {
+		log.Printf("Stream url of %s expired, refresh it", s.video.ID)
+		return s.refreshLocked(ctx)
+	}
+	if s.url == "" {
+		url, err := s.dl.getStreamURL(ctx, s.video, s.format)
+		if err != nil {
+			return "", err
+		}
+		s.url = url
+	}
+	return s.url, nil
+}
+
+// refresh fetches a new url after rejected was answered with a 403.
+// The parallel requests rejected with the same url share a single refresh.
+func (s *streamURL) refresh(ctx context.Context, rejected string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.url != rejected {
+		return s.url, nil
+	}
+	if s.refreshes >= maxURLRefreshes {
+		return "", fmt.Errorf("stream url of %s still rejected after %d refreshes", s.video.ID, s.refreshes)
+	}
+	log.Printf("Stream url of %s rejected, refresh it", s.video.ID)
+	s.refreshes++
+	return s.refreshLocked(ctx)
+}
+
+func (s *streamURL) refreshLocked(ctx context.Context) (string, error) {
+	// the info is fetched again in the middle of a download, not a new phase
+	v, err := s.dl.GetVideoInfo(WithProgressListener(ctx, nil), s.video.ID)
+	if err != nil {
+		return "", err
+	}
+	format := v.Formats.FindByItag(s.format.ItagNo)
+	if format == nil {
+		return "", fmt.Errorf("%w: itag %d", types.ErrFormatNotFound, s.format.ItagNo)
+	}
+	url, err := s.dl.getStreamURL(ctx, v, format)
+	if err != nil {
+		return "", err
+	}
+	s.url, s.expiresAt = url, v.ExpiresAt
+	return url, nil
+}
+
+// requestStream sends a GET of the stream with the Range header when not empty.
+// A 403 refreshes the url and sends the request again.
+func (dl *Downloader) requestStream(ctx context.Context, src *streamURL, rangeHeader string, expected ...int) (*http.Response, error) {
+	url, err := src.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		resp, err := dl.httpDo(req, expected...)
+		if !isForbidden(err) {
+			return resp, err
+		}
+		if url, err = src.refresh(ctx, url); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// DownloadTo writes the stream of the format to w, for pipelines and services which don't store it in a file.
+// It stops when the context is done and returns the bytes written. A failed request is sent again from the
+// last byte written, the progress is reported to the ProgressListener of the context.
+func (dl *Downloader) DownloadTo(ctx context.Context, v *types.Video, format *types.Format, w io.Writer) (written int64, err error) {
+	defer dl.budget.start()()
+	defer func() {
+		if err != nil {
+			reportResult(ctx, PhaseDownloading, v, "", err)
+		} else if l := progressListener(ctx); l != nil {
+			l.Finish(Progress{Phase: PhaseDownloading, VideoID: v.ID, Title: v.Title, BytesWritten: written, Total: written, Percent: 100}, "")
+		}
+	}()
+
+	if err := dl.budget.acquire(ctx); err != nil {
+		return 0, err
+	}
+	defer dl.budget.release()
+
+	src := newStreamURL(dl, v, format)
+	p := newProgress(ctx, v, 0, format.Size())
+	err = dl.retry(ctx, "stream of "+v.ID, func() error {
+		n, err := dl.copyStream(ctx, src, written, w, p)
+		written += n
+		return err
+	})
+	if ctx.Err() != nil {
+		return written, ctx.Err()
+	}
+	if err == nil && p != nil {
+		p.done()
+	}
+	return written, err
+}
+
+// copyStream copies the stream from offset to w and returns the bytes written
+func (dl *Downloader) copyStream(ctx context.Context, src *streamURL, offset int64, w io.Writer, p *progress) (int64, error) {
+	rangeHeader := ""
+	if offset > 0 {
+		rangeHeader = fmt.Sprintf("bytes=%d-", offset)
+	}
+	resp, err := dl.requestStream(ctx, src, rangeHeader, http.StatusOK, http.StatusPartialContent)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if offset > 0 && resp.StatusCode != http.StatusPartialContent {
+		// the bytes already written can't be taken back
+		return 0, errRangeIgnored
+	}
+
+	if p != nil {
+		w = io.MultiWriter(w, p)
+	}
+	n, err := io.Copy(w, dl.limitReader(ctx, resp.Body))
+	downloadedBytes.Add(float64(n))
+	if err == nil && resp.ContentLength > 0 && n < resp.ContentLength {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
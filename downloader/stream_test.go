@@ -0,0 +1,85 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bit-twit/yt-dl-go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadTo(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	var (
+		mu     sync.Mutex
+		ranges []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		first := len(ranges) == 1
+		mu.Unlock()
+		if first {
+			// the connection drops in the middle of the stream
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.Write(content[:300])
+			return
+		}
+		http.ServeContent(w, r, "stream.mp4", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	dl := NewDownloader(t.TempDir())
+	dl.Retry = RetryPolicy{BaseDelay: time.Millisecond}
+	video := &types.Video{ID: "BaW_jenozKc", Title: "streamed"}
+	format := &types.Format{ItagNo: 18, URL: srv.URL, MimeType: "video/mp4", ContentLength: strconv.Itoa(len(content))}
+
+	l := &recordingListener{}
+	var out bytes.Buffer
+	n, err := dl.DownloadTo(WithProgressListener(context.Background(), l), video, format, &out)
+	require.NoError(err)
+	assert.Equal(int64(len(content)), n)
+	assert.Equal(content, out.Bytes())
+	assert.Equal([]string{"", "bytes=300-"}, ranges)
+
+	require.NotEmpty(l.events)
+	assert.Equal("start 0/1000", l.events[0])
+	assert.Equal("finish 1000/1000", l.events[len(l.events)-1])
+	assert.NoFileExists(dl.OutputPath(video, format, ""))
+}
+
+func TestDownloadTo_RangeIgnored(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		if requests == 1 {
+			w.Write(content[:300])
+			return
+		}
+		w.Write(content)
+	}))
+	defer srv.Close()
+
+	dl := NewDownloader(t.TempDir())
+	dl.Retry = RetryPolicy{BaseDelay: time.Millisecond}
+	video := &types.Video{ID: "BaW_jenozKc", Title: "streamed"}
+	format := &types.Format{ItagNo: 18, URL: srv.URL, MimeType: "video/mp4"}
+
+	var out bytes.Buffer
+	n, err := dl.DownloadTo(context.Background(), video, format, &out)
+	// the whole stream sent again can't be appended to the bytes already written
+	assert.ErrorIs(t, err, errRangeIgnored)
+	assert.Equal(t, int64(300), n)
+	assert.Equal(t, content[:300], out.Bytes())
+}
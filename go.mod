@@ -6,10 +6,11 @@ require (
 	cloud.google.com/go v0.80.0 // indirect
 	github.com/golang/groupcache v0.0.0-20210331224755-41bb18bfe9da // indirect
 	github.com/golang/protobuf v1.5.2 // indirect
-	github.com/stretchr/testify v1.7.0 // indirect
+	github.com/prometheus/client_golang v1.11.1
+	github.com/stretchr/testify v1.7.0
 	golang.org/x/net v0.0.0-20210331212208-0fccb6fa2b5c // indirect
-	golang.org/x/oauth2 v0.0.0-20210323180902-22b0adad7558 // indirect
-	golang.org/x/sys v0.0.0-20210331175145-43e1dd70ce54 // indirect
-	google.golang.org/api v0.43.0 // indirect
+	golang.org/x/oauth2 v0.0.0-20210323180902-22b0adad7558
+	google.golang.org/api v0.43.0
 	google.golang.org/genproto v0.0.0-20210331142528-b7513248f0ba // indirect
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -1,75 +1,138 @@
 package main
 
 import (
-	"errors"
+	"context"
 	"flag"
 	"fmt"
-	"github.com/bit-twit/yt-dl-go/api"
-	"github.com/bit-twit/yt-dl-go/types"
-	"github.com/bit-twit/yt-dl-go/utils"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 )
 
-var (
-	apiKey       = flag.String("apiKey", "", "The API key from Google developer console.")
-	clientSecret = flag.String("clientSecret", "./resources/client_secret.json", "The OAuth web api client secret file from Google developer console.")
-	maxResults   = flag.Int64("maxResults", 5, "The maximum number of video resources to fetch from each playlist.")
-	playlistId   = flag.String("playlistId", "", "Retrieve information about specific playlist - otherwise it will retrieve all users's playlist.")
-	liked        = flag.Bool("liked", true, "Retrieve videos from special liked playlist.")
-)
+const programName = "yt-dl-go"
 
-func main() {
-	flag.Parse()
+// command is a single cli sub command with its own flag set and help text
+type command struct {
+	name  string
+	args  string // positional arguments shown in the usage line
+	short string // one line description shown in the command list
+	long  string // description shown in the command help
+	flags *flag.FlagSet
+	run   func(ctx context.Context, args []string) error
+}
+
+func newCommand(name, args, short, long string) *command {
+	cmd := &command{
+		name:  name,
+		args:  args,
+		short: short,
+		long:  long,
+		flags: flag.NewFlagSet(name, flag.ExitOnError),
+	}
+	cmd.flags.Usage = cmd.usage
+	return cmd
+}
 
-	finalApiKey := utils.GetEnv("YOUTUBE_API_KEY", *apiKey)
-	if finalApiKey == "" {
-		panic(errors.New("Expected YOUTUBE_API_KEY env or -apiKey param!"))
+func (c *command) usage() {
+	out := c.flags.Output()
+	fmt.Fprintf(out, "Usage: %s %s [flags] %s\n\n%s\n", programName, c.name, c.args, c.long)
+	hasFlags := false
+	c.flags.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		fmt.Fprint(out, "\nFlags:\n")
+		c.flags.PrintDefaults()
 	}
+}
 
-	finalClientSecret := utils.GetEnv("YOUTUBE_CLIENT_SECRET", *clientSecret)
-	if _, err := os.Stat(finalClientSecret); err != nil {
-		panic(err)
+func commands() []*command {
+	return []*command{
+		newListCommand(),
+		newInfoCommand(),
+		newFormatsCommand(),
+		newDownloadCommand(),
+		newSyncCommand(),
+		newServeCommand(),
+		newArchiveCommand(),
+		newConfigCommand(),
 	}
+}
 
-	yt := api.NewYoutubeAPI(types.Config{
-		true,
-		"youtube.googleapis.com",
-		443,
-		finalApiKey,
-		finalClientSecret,
-	})
+func usage(cmds []*command) {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags] [args]\n\nCommands:\n", programName)
+	for _, c := range cmds {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.short)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' for help on a command.\n", programName)
+	fmt.Fprintf(os.Stderr, "\nExit status: %d success, %d failure, %d usage or config error, %d authentication error,\n"+
+		"%d partial failure, %d interrupted.\n", exitOK, exitFailure, exitConfig, exitAuth, exitPartialFailure, exitInterrupted)
+}
 
-	var ps []string
-	var err error
-	if *playlistId != "" {
-		ps = []string{*playlistId}
-	} else {
-		// fetch all mine
-		ps, err = yt.ListPlaylists()
-		if err != nil {
-			panic(err)
-		}
-		fmt.Print("Playlist ids : ")
-		fmt.Printf("%+v\n", ps)
+func main() {
+	cmds := commands()
+	if len(os.Args) < 2 {
+		usage(cmds)
+		os.Exit(exitConfig)
 	}
 
-	// fetch video information from playlists
-	vs := make([]types.Video, 0, 5000)
-	for _, p := range ps {
-		if pVideos, err := yt.ListVideosForPlaylist(p, *maxResults); err == nil {
-			vs = append(vs, pVideos...)
+	name := os.Args[1]
+	switch name {
+	case "help", "-h", "-help", "--help":
+		usage(cmds)
+		return
+	}
+
+	for _, c := range cmds {
+		if c.name != name {
+			continue
 		}
+		os.Exit(runCommand(c, os.Args[2:]))
 	}
 
-	// fetch liked videos
-	if *liked {
-		if lVideos, err := yt.ListVideosLiked(*maxResults); err == nil {
-			vs = append(vs, lVideos...)
+	fmt.Fprintf(os.Stderr, "%s: unknown command %q\n\n", programName, name)
+	usage(cmds)
+	os.Exit(exitConfig)
+}
+
+// runCommand runs the command until it completes or until SIGINT or SIGTERM is received
+func runCommand(c *command, args []string) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := c.run(ctx, parseArgs(c.flags, args))
+	if ctx.Err() != nil {
+		fmt.Fprintf(os.Stderr, "%s %s: interrupted\n", programName, c.name)
+		return exitInterrupted
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %s: %v\n", programName, c.name, err)
+	}
+	return exitCode(err)
+}
+
+// parseArgs parses the flags found anywhere in args and returns the positional arguments.
+// Everything after a "--" terminator is positional.
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		rest := fs.Args()
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...)
 		}
+		if len(rest) == 0 {
+			return positional
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
 	}
+}
 
-	fmt.Printf("Found %d videos: \n", len(vs))
-	for _, v := range vs {
-		fmt.Println(v.ID)
+// requireArgs checks that at least n positional arguments were passed to the command
+func requireArgs(c *command, args []string, n int) error {
+	if len(args) < n {
+		c.flags.Usage()
+		return configError(fmt.Errorf("expected %s", strings.TrimSpace(c.args)))
 	}
+	return nil
 }
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseArgs(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	outputDir := fs.String("outputDir", ".", "")
+	audioOnly := fs.Bool("audioOnly", false, "")
+
+	args := parseArgs(fs, []string{"id1", "-outputDir", "/tmp", "id2", "-audioOnly", "--", "-id3"})
+	assert.Equal(t, []string{"id1", "id2", "-id3"}, args)
+	assert.Equal(t, "/tmp", *outputDir)
+	assert.True(t, *audioOnly)
+}
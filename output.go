@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+)
+
+// outputFormat selects how the listing commands print their results
+type outputFormat string
+
+const (
+	outputText   outputFormat = "text"   // human readable
+	outputJSON   outputFormat = "json"   // a single indented JSON document
+	outputNDJSON outputFormat = "ndjson" // one JSON object per line
+)
+
+func registerOutputFlag(fs *flag.FlagSet) *string {
+	return fs.String("output", string(outputText), "Output format: text, json or ndjson.")
+}
+
+func parseOutputFormat(s string) (outputFormat, error) {
+	switch f := outputFormat(s); f {
+	case outputText, outputJSON, outputNDJSON:
+		return f, nil
+	}
+	return "", configError(fmt.Errorf("unknown output format %q, expected text, json or ndjson", s))
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func writeNDJSON(w io.Writer, items ...interface{}) error {
+	enc := json.NewEncoder(w)
+	for _, item := range items {
+		if err := enc.Encode(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/bit-twit/yt-dl-go/downloader"
+	"github.com/bit-twit/yt-dl-go/utils"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	barWidth   = 24
+	titleWidth = 32
+
+	// progressLogInterval limits how often the bytes written are logged without terminal
+	progressLogInterval = 5 * time.Second
+)
+
+// progressBars draws a line per running download when the output is a terminal, the lines of the standard
+// logger are printed above them. Otherwise it logs the start, the progress every few seconds and the end.
+type progressBars struct {
+	mu        sync.Mutex
+	out       io.Writer
+	tty       bool
+	plain     *log.Logger
+	bars      []*progressBar // running downloads, in start order
+	drawn     int            // lines drawn below the logs, erased before drawing again
+	logOutput io.Writer      // output of the standard logger, restored by stop
+}
+
+type progressBar struct {
+	progress downloader.Progress
+	lastLog  time.Time
+}
+
+// newProgressBars draws the progress bars on out when it is a terminal, call stop once the downloads returned
+func newProgressBars(out *os.File) *progressBars {
+	b := &progressBars{out: out, tty: isTerminal(out), plain: log.New(out, "", log.LstdFlags)}
+	if b.tty {
+		b.logOutput = log.Writer()
+		log.SetOutput(b)
+	}
+	return b
+}
+
+// isTerminal tells if the file is a terminal understanding the cursor escape sequences
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0 && os.Getenv("TERM") != "dumb"
+}
+
+// stop erases the bars left and restores the output of the standard logger, b can be nil
+func (b *progressBars) stop() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tty {
+		b.bars = nil
+		b.redraw("")
+		log.SetOutput(b.logOutput)
+	}
+}
+
+// Write prints a line of the standard logger above the bars
+func (b *progressBars) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.redraw(string(p))
+	return len(p), nil
+}
+
+func (b *progressBars) Start(p downloader.Progress) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	bar := b.bar(p)
+	bar.lastLog = time.Now()
+	if b.tty {
+		b.redraw("")
+		return
+	}
+	total := "unknown size"
+	if p.Total > 0 {
+		total = utils.FormatBytes(p.Total)
+	}
+	if p.BytesWritten > 0 {
+		b.plain.Printf("%s: resume at %s of %s", progressTitle(p), utils.FormatBytes(p.BytesWritten), total)
+	} else {
+		b.plain.Printf("%s: start, %s", progressTitle(p), total)
+	}
+}
+
+func (b *progressBars) Progress(p downloader.Progress) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	bar := b.bar(p)
+	if b.tty {
+		b.redraw("")
+		return
+	}
+	switch p.Phase {
+	case downloader.PhaseConverting:
+		b.plain.Printf("%s: converting", progressTitle(p))
+	case downloader.PhaseDownloading:
+		if time.Since(bar.lastLog) >= progressLogInterval {
+			bar.lastLog = time.Now()
+			b.plain.Printf("%s: %s", progressTitle(p), progressLine(p))
+		}
+	}
+}
+
+func (b *progressBars) Finish(p downloader.Progress, file string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remove(p.VideoID)
+	line := fmt.Sprintf("%s: done, %s", progressTitle(p), utils.FormatBytes(p.BytesWritten))
+	if file != "" {
+		line += " " + file
+	}
+	if b.tty {
+		b.redraw(line + "\n")
+		return
+	}
+	b.plain.Print(line)
+}
+
+func (b *progressBars) Fail(p downloader.Progress, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remove(p.VideoID)
+	line := fmt.Sprintf("%s: failed, %v", progressTitle(p), err)
+	if b.tty {
+		b.redraw(line + "\n")
+		return
+	}
+	b.plain.Print(line)
+}
+
+// bar returns the bar of the video, added when missing, updated with the progress
+func (b *progressBars) bar(p downloader.Progress) *progressBar {
+	for _, bar := range b.bars {
+		if bar.progress.VideoID == p.VideoID {
+			if p.Title == "" {
+				p.Title = bar.progress.Title
+			}
+			bar.progress = p
+			return bar
+		}
+	}
+	bar := &progressBar{progress: p}
+	b.bars = append(b.bars, bar)
+	return bar
+}
+
+func (b *progressBars) remove(videoID string) {
+	for i, bar := range b.bars {
+		if bar.progress.VideoID == videoID {
+			b.bars = append(b.bars[:i], b.bars[i+1:]...)
+			return
+		}
+	}
+}
+
+// redraw erases the bars, prints the lines above them and draws the bars again
+func (b *progressBars) redraw(above string) {
+	var buf bytes.Buffer
+	if b.drawn > 0 {
+		// move to the first bar and erase down to the end of the screen
+		fmt.Fprintf(&buf, "\r\x1b[%dA", b.drawn)
+	}
+	buf.WriteString("\x1b[J")
+	buf.WriteString(above)
+	for _, bar := range b.bars {
+		fmt.Fprintf(&buf, "%-*s %s\n", titleWidth, progressTitle(bar.progress), barLine(bar.progress))
+	}
+	b.drawn = len(b.bars)
+	b.out.Write(buf.Bytes())
+}
+
+// barLine draws the phase of a video, or its bar with the bytes written, speed and ETA
+func barLine(p downloader.Progress) string {
+	switch p.Phase {
+	case downloader.PhaseFetchingInfo:
+		return "fetching info"
+	case downloader.PhaseConverting:
+		return "converting"
+	}
+	if p.Total <= 0 {
+		return progressLine(p)
+	}
+	filled := int(p.Percent / 100 * barWidth)
+	if filled > barWidth {
+		filled = barWidth
+	}
+	return "[" + strings.Repeat("=", filled) + strings.Repeat(" ", barWidth-filled) + "] " + progressLine(p)
+}
+
+// progressLine formats the bytes written, speed and ETA of a download, eg: 45.0% 4.5MiB/10.0MiB 1.2MiB/s ETA 5s
+func progressLine(p downloader.Progress) string {
+	line := utils.FormatBytes(p.BytesWritten)
+	if p.Total > 0 {
+		line = fmt.Sprintf("%5.1f%% %s/%s", p.Percent, line, utils.FormatBytes(p.Total))
+	}
+	line += fmt.Sprintf(" %s/s", utils.FormatBytes(int64(p.Speed)))
+	if p.ETA > 0 {
+		line += " ETA " + p.ETA.Round(time.Second).String()
+	}
+	return line
+}
+
+// progressTitle returns the title of the video, cut to titleWidth, or its id while the info is fetched
+func progressTitle(p downloader.Progress) string {
+	t := p.Title
+	if t == "" {
+		t = p.VideoID
+	}
+	if r := []rune(t); len(r) > titleWidth {
+		t = string(r[:titleWidth-1]) + "…"
+	}
+	return t
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bit-twit/yt-dl-go/downloader"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressBars_Terminal(t *testing.T) {
+	var out bytes.Buffer
+	b := &progressBars{out: &out, tty: true}
+
+	b.Progress(downloader.Progress{Phase: downloader.PhaseFetchingInfo, VideoID: "BaW_jenozKc"})
+	b.Start(downloader.Progress{Phase: downloader.PhaseDownloading, VideoID: "BaW_jenozKc", Title: "First", Total: 2048})
+	b.Progress(downloader.Progress{Phase: downloader.PhaseFetchingInfo, VideoID: "QcHvzNBtlOw"})
+	out.Reset()
+
+	b.Progress(downloader.Progress{Phase: downloader.PhaseDownloading, VideoID: "BaW_jenozKc", Title: "First",
+		BytesWritten: 1024, Total: 2048, Percent: 50, Speed: 512, ETA: 2 * time.Second})
+	assert.Equal(t, "\r\x1b[2A\x1b[J"+
+		"First                            [============            ]  50.0% 1.0KiB/2.0KiB 512B/s ETA 2s\n"+
+		"QcHvzNBtlOw                      fetching info\n", out.String())
+
+	out.Reset()
+	b.Write([]byte("a log line\n"))
+	assert.True(t, strings.HasPrefix(out.String(), "\r\x1b[2A\x1b[Ja log line\nFirst "), out.String())
+
+	out.Reset()
+	b.Finish(downloader.Progress{VideoID: "BaW_jenozKc", Title: "First", BytesWritten: 2048, Total: 2048}, "First.mp4")
+	assert.Equal(t, "\r\x1b[2A\x1b[JFirst: done, 2.0KiB First.mp4\n"+
+		"QcHvzNBtlOw                      fetching info\n", out.String())
+
+	out.Reset()
+	b.Fail(downloader.Progress{VideoID: "QcHvzNBtlOw"}, errors.New("unavailable"))
+	assert.Equal(t, "\r\x1b[1A\x1b[JQcHvzNBtlOw: failed, unavailable\n", out.String())
+	assert.Empty(t, b.bars)
+}
+
+func TestProgressBars_Plain(t *testing.T) {
+	var out bytes.Buffer
+	b := &progressBars{out: &out, plain: log.New(&out, "", 0)}
+
+	b.Progress(downloader.Progress{Phase: downloader.PhaseFetchingInfo, VideoID: "BaW_jenozKc"})
+	b.Start(downloader.Progress{Phase: downloader.PhaseDownloading, VideoID: "BaW_jenozKc", Title: "First", Total: 2048})
+	// throttled
+	b.Progress(downloader.Progress{Phase: downloader.PhaseDownloading, VideoID: "BaW_jenozKc", Title: "First", BytesWritten: 1024, Total: 2048})
+	b.bars[0].lastLog = time.Now().Add(-progressLogInterval)
+	b.Progress(downloader.Progress{Phase: downloader.PhaseDownloading, VideoID: "BaW_jenozKc", Title: "First",
+		BytesWritten: 1536, Total: 2048, Percent: 75, Speed: 1024})
+	b.Progress(downloader.Progress{Phase: downloader.PhaseConverting, VideoID: "BaW_jenozKc", Title: "First"})
+	b.Finish(downloader.Progress{VideoID: "BaW_jenozKc", Title: "First", BytesWritten: 2048}, "First.mp3")
+
+	assert.Equal(t, "First: start, 2.0KiB\n"+
+		"First:  75.0% 1.5KiB/2.0KiB 1.0KiB/s\n"+
+		"First: converting\n"+
+		"First: done, 2.0KiB First.mp3\n", out.String())
+	assert.NotContains(t, out.String(), "\x1b")
+}
+
+func TestProgressTitle(t *testing.T) {
+	assert.Equal(t, "BaW_jenozKc", progressTitle(downloader.Progress{VideoID: "BaW_jenozKc"}))
+	long := progressTitle(downloader.Progress{Title: strings.Repeat("é", 40)})
+	assert.Equal(t, titleWidth, len([]rune(long)))
+	assert.True(t, strings.HasSuffix(long, "…"))
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bit-twit/yt-dl-go/types"
+	"github.com/bit-twit/yt-dl-go/utils"
+	"io"
+)
+
+// Exit statuses of the commands
+const (
+	exitOK             = 0
+	exitFailure        = 1   // every playlist or video failed, or an unexpected error
+	exitConfig         = 2   // invalid usage, flags or config
+	exitAuth           = 3   // the Youtube Data API credentials were rejected
+	exitPartialFailure = 4   // some playlists or videos failed
+	exitInterrupted    = 130 // stopped by SIGINT or SIGTERM, as shells do
+)
+
+// exitError carries the exit status of a failed command
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// configError marks an invalid usage, flag or config
+func configError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code: exitConfig, err: err}
+}
+
+// exitCode returns the exit status matching the error returned by a command
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	var authErr *types.AuthError
+	if errors.As(err, &authErr) {
+		return exitAuth
+	}
+	return exitFailure
+}
+
+// runFailure is a playlist which couldn't be listed
+type runFailure struct {
+	PlaylistID string
+	Err        error
+}
+
+// runReport collects the outcome of the playlists and videos processed during a run
+type runReport struct {
+	playlists         int
+	playlistFailures  []runFailure
+	downloads         []downloadResult
+	downloadsReported bool // the downloads summary is printed even when empty
+}
+
+func (r *runReport) addPlaylist(id string, err error) {
+	r.playlists++
+	if err != nil {
+		r.playlistFailures = append(r.playlistFailures, runFailure{PlaylistID: id, Err: err})
+	}
+}
+
+func (r *runReport) addDownloads(results ...downloadResult) {
+	r.downloadsReported = true
+	r.downloads = append(r.downloads, results...)
+}
+
+// print writes the outcome of every download and the reason of every failed playlist
+func (r *runReport) print(w io.Writer) {
+	if r.downloadsReported {
+		printSummary(w, r.downloads)
+	}
+	if len(r.playlistFailures) > 0 {
+		fmt.Fprintf(w, "Failed playlists: \n")
+		for _, f := range r.playlistFailures {
+			fmt.Fprintf(w, "FAIL %s: %v\n", playlistName(f.PlaylistID), f.Err)
+		}
+	}
+}
+
+// err returns nil when everything succeeded, otherwise a partial or a total failure
+func (r *runReport) err() error {
+	total := r.playlists + len(r.downloads)
+	failed := len(r.playlistFailures)
+	for _, d := range r.downloads {
+		if d.Err != nil {
+			failed++
+		}
+	}
+
+	switch {
+	case failed == 0:
+		return nil
+	case failed == total:
+		return &exitError{code: exitFailure, err: fmt.Errorf("all %d playlists and videos failed", total)}
+	default:
+		return &exitError{code: exitPartialFailure, err: fmt.Errorf("%d of %d playlists and videos failed", failed, total)}
+	}
+}
+
+func playlistName(id string) string {
+	if id == "" {
+		return "liked videos"
+	}
+	return "playlist " + id
+}
+
+// printSummary prints the outcome of every download, or the plan of every download in dry-run mode
+func printSummary(w io.Writer, results []downloadResult) {
+	failed, skipped, planned, plannedSize := 0, 0, 0, int64(0)
+	header := "Downloaded %d videos: \n"
+	for _, r := range results {
+		if r.Plan != nil {
+			header = "Dry run of %d videos: \n"
+			break
+		}
+	}
+	fmt.Fprintf(w, header, len(results))
+	for _, r := range results {
+		if r.Line > 0 {
+			fmt.Fprintf(w, "line %d: ", r.Line)
+		}
+		name := r.Video.ID
+		if r.Video.Title != "" {
+			name += " (" + r.Video.Title + ")"
+		}
+		switch {
+		case r.Err != nil:
+			failed++
+			fmt.Fprintf(w, "FAIL %s: %v\n", name, r.Err)
+		case r.Duplicate:
+			skipped++
+			fmt.Fprintf(w, "SKIP %s: given twice\n", name)
+		case r.Skipped:
+			skipped++
+			fmt.Fprintf(w, "SKIP %s: already in the archive\n", name)
+		case r.Plan != nil:
+			planned++
+			plannedSize += r.Plan.EstimatedSize
+			fmt.Fprintf(w, "PLAN %s: itag %d %s -> %s (%s)\n", name, r.Plan.Format.ItagNo, r.Plan.Format.MimeType,
+				r.Plan.FinalFile, estimatedSize(r.Plan.EstimatedSize))
+		default:
+			fmt.Fprintf(w, "OK   %s: %s\n", name, r.File)
+		}
+	}
+	skippedCount := ""
+	if skipped > 0 {
+		skippedCount = fmt.Sprintf(", %d skipped", skipped)
+	}
+	if planned > 0 {
+		fmt.Fprintf(w, "dry run: %d planned, %s estimated%s, %d failed\n", planned, estimatedSize(plannedSize), skippedCount, failed)
+		return
+	}
+	fmt.Fprintf(w, "%d succeeded%s, %d failed\n", len(results)-failed-skipped, skippedCount, failed)
+}
+
+func estimatedSize(size int64) string {
+	if size <= 0 {
+		return "unknown size"
+	}
+	return utils.FormatBytes(size)
+}
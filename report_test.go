@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bit-twit/yt-dl-go/downloader"
+	"github.com/bit-twit/yt-dl-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, exitOK, exitCode(nil))
+	assert.Equal(t, exitFailure, exitCode(errors.New("boom")))
+	assert.Equal(t, exitConfig, exitCode(configError(errors.New("bad flag"))))
+	assert.Equal(t, exitAuth, exitCode(fmt.Errorf("listing: %w", &types.AuthError{Err: errors.New("401")})))
+	assert.Equal(t, exitFailure, exitCode(context.Canceled))
+}
+
+func TestRunReport(t *testing.T) {
+	var r runReport
+	assert.NoError(t, r.err())
+
+	r.addPlaylist("PL1", nil)
+	r.addDownloads(downloadResult{Video: types.Video{ID: "BaW_jenozKc"}, File: "a.mp4"})
+	assert.NoError(t, r.err())
+
+	r.addPlaylist("", errors.New("quota exceeded"))
+	r.addDownloads(downloadResult{Video: types.Video{ID: "QcHvzNBtlOw"}, Line: 3, Err: types.ErrFormatNotFound})
+	assert.Equal(t, exitPartialFailure, exitCode(r.err()))
+
+	var buf bytes.Buffer
+	r.print(&buf)
+	assert.Contains(t, buf.String(), "OK   BaW_jenozKc: a.mp4")
+	assert.Contains(t, buf.String(), "line 3: FAIL QcHvzNBtlOw: format not found")
+	assert.Contains(t, buf.String(), "FAIL liked videos: quota exceeded")
+
+	failed := runReport{}
+	failed.addPlaylist("PL1", errors.New("not found"))
+	assert.Equal(t, exitFailure, exitCode(failed.err()))
+}
+
+func TestPrintSummary_DryRun(t *testing.T) {
+	format := &types.Format{ItagNo: 18, MimeType: "video/mp4"}
+	results := []downloadResult{
+		{Video: types.Video{ID: "BaW_jenozKc"}, Plan: &downloader.DownloadPlan{Format: format, FinalFile: "a.mp4", EstimatedSize: 2048}},
+		{Video: types.Video{ID: "QcHvzNBtlOw"}, Plan: &downloader.DownloadPlan{Format: format, FinalFile: "b.mp4"}},
+	}
+
+	var buf bytes.Buffer
+	printSummary(&buf, results)
+	assert.Contains(t, buf.String(), "Dry run of 2 videos")
+	assert.Contains(t, buf.String(), "PLAN BaW_jenozKc: itag 18 video/mp4 -> a.mp4 (2.0KiB)")
+	assert.Contains(t, buf.String(), "PLAN QcHvzNBtlOw: itag 18 video/mp4 -> b.mp4 (unknown size)")
+	assert.Contains(t, buf.String(), "dry run: 2 planned, 2.0KiB estimated, 0 failed")
+}
+
+func TestPrintSummary_Skipped(t *testing.T) {
+	results := []downloadResult{
+		{Video: types.Video{ID: "BaW_jenozKc"}, File: "a.mp4"},
+		{Video: types.Video{ID: "QcHvzNBtlOw"}, Skipped: true},
+		{Video: types.Video{ID: "BaW_jenozKc"}, Skipped: true, Duplicate: true},
+	}
+
+	var buf bytes.Buffer
+	printSummary(&buf, results)
+	assert.Contains(t, buf.String(), "SKIP QcHvzNBtlOw: already in the archive")
+	assert.Contains(t, buf.String(), "SKIP BaW_jenozKc: given twice")
+	assert.Contains(t, buf.String(), "1 succeeded, 2 skipped, 0 failed")
+
+	var r runReport
+	r.addDownloads(results...)
+	assert.NoError(t, r.err())
+}
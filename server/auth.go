@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/bit-twit/yt-dl-go/types"
+	"github.com/bit-twit/yt-dl-go/utils"
+	"net/http"
+	"strings"
+)
+
+var ErrUnauthorized = errors.New("missing or invalid credentials")
+
+// User is a client of the server, authenticated by a bearer token or basic auth
+type User struct {
+	Name     string
+	Token    string
+	Password string
+	Quota    Quota
+	Admin    bool // sees and cancels the jobs of every user
+}
+
+// Quota limits the jobs of a user, zero values are unlimited
+type Quota struct {
+	MaxJobs        int   // queued and running jobs
+	MaxBytesPerDay int64 // bytes downloaded since midnight
+}
+
+// UsersFromConfig validates the configured users of the server
+func UsersFromConfig(config []types.UserConfig) ([]User, error) {
+	users := make([]User, 0, len(config))
+	names := map[string]bool{}
+	for _, c := range config {
+		if c.Name == "" {
+			return nil, errors.New("server user without name")
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("server user %q is defined twice", c.Name)
+		}
+		names[c.Name] = true
+		if c.Token == "" && c.Password == "" {
+			return nil, fmt.Errorf("server user %q needs a token or a password", c.Name)
+		}
+
+		user := User{Name: c.Name, Token: c.Token, Password: c.Password, Admin: c.Admin,
+			Quota: Quota{MaxJobs: c.MaxJobs}}
+		if c.MaxBytesPerDay != "" {
+			max, err := utils.ParseBytes(c.MaxBytesPerDay)
+			if err != nil {
+				return nil, fmt.Errorf("server user %q maxBytesPerDay: %w", c.Name, err)
+			}
+			user.Quota.MaxBytesPerDay = max
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+type userKey struct{}
+
+// userFromRequest returns the authenticated user of the request, nil when the server doesn't require authentication
+func userFromRequest(r *http.Request) *User {
+	user, _ := r.Context().Value(userKey{}).(*User)
+	return user
+}
+
+// canAccess tells if the user sees the job: its owner, an admin, or anyone when the server doesn't require authentication
+func canAccess(user *User, job Job) bool {
+	return user == nil || user.Admin || job.SubmittedBy == user.Name
+}
+
+// authenticate rejects the requests without valid credentials when users are configured
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	if len(s.users) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := s.lookupUser(r)
+		if user == nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="yt-dl-go"`)
+			writeError(w, http.StatusUnauthorized, ErrUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userKey{}, user)))
+	})
+}
+
+func (s *Server) lookupUser(r *http.Request) *User {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token := strings.TrimPrefix(auth, "Bearer ")
+		for i := range s.users {
+			if s.users[i].Token != "" && secureEqual(s.users[i].Token, token) {
+				return &s.users[i]
+			}
+		}
+		return nil
+	}
+
+	name, password, ok := r.BasicAuth()
+	if !ok {
+		return nil
+	}
+	for i := range s.users {
+		if s.users[i].Name == name && s.users[i].Password != "" && secureEqual(s.users[i].Password, password) {
+			return &s.users[i]
+		}
+	}
+	return nil
+}
+
+func secureEqual(expected, actual string) bool {
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(actual)) == 1
+}
@@ -0,0 +1,238 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bit-twit/yt-dl-go/downloader"
+	"github.com/bit-twit/yt-dl-go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsersFromConfig(t *testing.T) {
+	users, err := UsersFromConfig([]types.UserConfig{
+		{Name: "alice", Token: "alice-token", MaxJobs: 2, MaxBytesPerDay: "1G"},
+		{Name: "bob", Password: "bob-password", Admin: true},
+	})
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	assert.Equal(t, Quota{MaxJobs: 2, MaxBytesPerDay: 1 << 30}, users[0].Quota)
+	assert.Equal(t, Quota{}, users[1].Quota)
+	assert.False(t, users[0].Admin)
+	assert.True(t, users[1].Admin)
+
+	for name, config := range map[string][]types.UserConfig{
+		"no name":        {{Token: "token"}},
+		"no credentials": {{Name: "alice"}},
+		"duplicate":      {{Name: "alice", Token: "a"}, {Name: "alice", Token: "b"}},
+		"invalid bytes":  {{Name: "alice", Token: "a", MaxBytesPerDay: "lots"}},
+	} {
+		_, err := UsersFromConfig(config)
+		assert.Error(t, err, name)
+	}
+}
+
+func submitAs(t *testing.T, srv *httptest.Server, setAuth func(*http.Request)) (*http.Response, Job) {
+	body, _ := json.Marshal(JobRequest{URL: testVideoID})
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/jobs", bytes.NewReader(body))
+	setAuth(req)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var job Job
+	json.NewDecoder(resp.Body).Decode(&job)
+	return resp, job
+}
+
+func bearer(token string) func(*http.Request) {
+	return func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+token) }
+}
+
+func TestServer_Authentication(t *testing.T) {
+	srv, _ := newTestServerWithUsers(t, &fakeYoutube{}, []User{
+		{Name: "alice", Token: "alice-token"},
+		{Name: "bob", Password: "bob-password"},
+	})
+
+	resp, err := http.Get(srv.URL + "/")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, `Basic realm="yt-dl-go"`, resp.Header.Get("WWW-Authenticate"))
+
+	resp, _ = submitAs(t, srv, bearer("wrong-token"))
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp, _ = submitAs(t, srv, func(req *http.Request) { req.SetBasicAuth("bob", "wrong-password") })
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp, _ = submitAs(t, srv, func(req *http.Request) { req.SetBasicAuth("alice", "alice-token") })
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, job := submitAs(t, srv, bearer("alice-token"))
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Equal(t, "alice", job.SubmittedBy)
+
+	resp, job = submitAs(t, srv, func(req *http.Request) { req.SetBasicAuth("bob", "bob-password") })
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Equal(t, "bob", job.SubmittedBy)
+}
+
+func TestServer_MaxJobsQuota(t *testing.T) {
+	srv, _ := newTestServerWithUsers(t, &fakeYoutube{block: true}, []User{
+		{Name: "alice", Token: "alice-token", Quota: Quota{MaxJobs: 1}},
+		{Name: "bob", Token: "bob-token"},
+	})
+
+	resp, _ := submitAs(t, srv, bearer("alice-token"))
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	resp, _ = submitAs(t, srv, bearer("alice-token"))
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+
+	// the quota is per user
+	resp, _ = submitAs(t, srv, bearer("bob-token"))
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+}
+
+func TestServer_BytesPerDayQuota(t *testing.T) {
+	size := int64(len(testStream))
+	srv, _ := newTestServerWithUsers(t, &fakeYoutube{}, []User{
+		{Name: "alice", Token: "alice-token", Quota: Quota{MaxBytesPerDay: size}},
+		{Name: "bob", Token: "bob-token", Quota: Quota{MaxBytesPerDay: size - 1}},
+	})
+
+	resp, job := submitAs(t, srv, bearer("alice-token"))
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	waitForStatusAs(t, srv, job.ID, JobDone, bearer("alice-token"))
+
+	// the quota is used up for today
+	resp, _ = submitAs(t, srv, bearer("alice-token"))
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+
+	// the format doesn't fit in the quota
+	resp, job = submitAs(t, srv, bearer("bob-token"))
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	job = waitForStatusAs(t, srv, job.ID, JobFailed, bearer("bob-token"))
+	assert.Contains(t, job.Error, ErrQuotaExceeded.Error())
+}
+
+func waitForStatusAs(t *testing.T, srv *httptest.Server, id string, status JobStatus, setAuth func(*http.Request)) Job {
+	var job Job
+	require.Eventually(t, func() bool {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/jobs/"+id, nil)
+		setAuth(req)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		return json.NewDecoder(resp.Body).Decode(&job) == nil && job.Status == status
+	}, 5*time.Second, 10*time.Millisecond)
+	return job
+}
+
+func TestManager_ResumedBytesNotCounted(t *testing.T) {
+	m := NewManager(downloader.NewDownloader(t.TempDir()), 1, types.FormatConfig{})
+	canceled := false
+	job := &Job{ID: "job", SubmittedBy: "alice", Status: JobRunning, quota: Quota{MaxBytesPerDay: 100},
+		cancel: func() { canceled = true }}
+	m.jobs[job.ID] = job
+	l := jobListener{m: m, job: job}
+
+	// the part file of a previous job already holds 900 bytes
+	l.Start(downloader.Progress{Phase: downloader.PhaseDownloading, BytesWritten: 900, Total: 1000})
+	l.Progress(downloader.Progress{Phase: downloader.PhaseDownloading, BytesWritten: 950, Total: 1000})
+	assert.Equal(t, int64(50), job.bytesWritten)
+	assert.False(t, canceled)
+
+	l.Finish(downloader.Progress{Phase: downloader.PhaseDownloading, BytesWritten: 1000, Total: 1000}, "a.mp4")
+	assert.Equal(t, int64(50), job.bytesWritten)
+	assert.Equal(t, int64(1000), job.Progress.BytesWritten)
+	assert.False(t, canceled)
+}
+
+func TestManager_RunningBytes(t *testing.T) {
+	m := NewManager(downloader.NewDownloader(t.TempDir()), 1, types.FormatConfig{})
+	job := &Job{ID: "job", SubmittedBy: "alice", Status: JobRunning}
+	m.jobs[job.ID] = job
+	l := jobListener{m: m, job: job}
+
+	l.Progress(downloader.Progress{Phase: downloader.PhaseDownloading, BytesWritten: 40, Total: 100})
+	l.Progress(downloader.Progress{Phase: downloader.PhaseDownloading, BytesWritten: 70, Total: 100})
+	assert.Equal(t, int64(70), m.usedBytes("alice"))
+	assert.Equal(t, int64(0), m.usedBytes("bob"))
+
+	// the bytes of the finished job move to the daily usage
+	m.addUsage(job)
+	assert.Empty(t, m.runningBytes)
+	assert.Equal(t, int64(70), m.usedBytes("alice"))
+}
+
+func TestServer_JobOwnership(t *testing.T) {
+	srv, _ := newTestServerWithUsers(t, &fakeYoutube{block: true}, []User{
+		{Name: "alice", Token: "alice-token"},
+		{Name: "bob", Token: "bob-token"},
+		{Name: "admin", Token: "admin-token", Admin: true},
+	})
+	resp, job := submitAs(t, srv, bearer("alice-token"))
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	do := func(method, path, token string) *http.Response {
+		req, _ := http.NewRequest(method, srv.URL+path, nil)
+		bearer(token)(req)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+	listAs := func(token string) []Job {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/jobs", nil)
+		bearer(token)(req)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var jobs []Job
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&jobs))
+		return jobs
+	}
+
+	// the jobs of the other users are not found
+	assert.Empty(t, listAs("bob-token"))
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/jobs/"+job.ID, "bob-token").StatusCode)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/jobs/"+job.ID+"/events", "bob-token").StatusCode)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/jobs/"+job.ID, "bob-token").StatusCode)
+
+	assert.Len(t, listAs("alice-token"), 1)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/jobs/"+job.ID, "alice-token").StatusCode)
+
+	// the admins see and cancel every job
+	assert.Len(t, listAs("admin-token"), 1)
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/jobs/"+job.ID, "admin-token").StatusCode)
+}
+
+func TestServer_StreamQuota(t *testing.T) {
+	size := int64(len(testStream))
+	srv, _ := newTestServerWithUsers(t, &fakeYoutube{}, []User{
+		{Name: "alice", Token: "alice-token", Quota: Quota{MaxBytesPerDay: size}},
+	})
+	stream := func() *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/stream/"+testVideoID+"?itag=18", nil)
+		bearer("alice-token")(req)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp
+	}
+
+	assert.Equal(t, http.StatusOK, stream().StatusCode)
+	// the proxied bytes used up the quota of the day, for the streams and the jobs
+	assert.Equal(t, http.StatusTooManyRequests, stream().StatusCode)
+	resp, _ := submitAs(t, srv, bearer("alice-token"))
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+}
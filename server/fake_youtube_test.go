@@ -0,0 +1,115 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	testVideoID   = "BaW_jenozKc"
+	testStreamURL = "https://fake.googlevideo.com/videoplayback"
+)
+
+var testStream = []byte("fake mp4 stream content")
+
+// fakeYoutube answers the video info and stream requests of the downloader without network
+type fakeYoutube struct {
+	// block makes the stream hang after the first bytes until the request is canceled
+	block bool
+}
+
+func (f *fakeYoutube) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch {
+	case req.URL.Path == "/get_video_info":
+		return f.respond(req, http.StatusOK, "application/x-www-form-urlencoded", []byte(videoInfoBody()), nil), nil
+	case req.URL.String() == testStreamURL:
+		if f.block {
+			r, w := io.Pipe()
+			go func() {
+				w.Write(testStream[:4])
+				<-req.Context().Done()
+				w.CloseWithError(req.Context().Err())
+			}()
+			return f.respond(req, http.StatusOK, "video/mp4", nil, r), nil
+		}
+		if start, end, ok := parseTestRange(req.Header.Get("Range")); ok {
+			if start >= len(testStream) {
+				return f.respond(req, http.StatusRequestedRangeNotSatisfiable, "text/plain", nil, nil), nil
+			}
+			if end >= len(testStream) {
+				end = len(testStream) - 1
+			}
+			resp := f.respond(req, http.StatusPartialContent, "video/mp4", testStream[start:end+1], nil)
+			resp.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(testStream)))
+			return resp, nil
+		}
+		resp := f.respond(req, http.StatusOK, "video/mp4", testStream, nil)
+		resp.Header.Set("Accept-Ranges", "bytes")
+		return resp, nil
+	}
+	return f.respond(req, http.StatusNotFound, "text/plain", []byte("not found"), nil), nil
+}
+
+func (f *fakeYoutube) respond(req *http.Request, status int, contentType string, body []byte, reader io.ReadCloser) *http.Response {
+	resp := &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Header:     http.Header{"Content-Type": {contentType}},
+		Request:    req,
+	}
+	if reader != nil {
+		resp.Body = reader
+		resp.ContentLength = -1
+	} else {
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		resp.ContentLength = int64(len(body))
+		resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+	return resp
+}
+
+// parseTestRange parses the "bytes=start-end" and "bytes=start-" ranges
+func parseTestRange(header string) (start, end int, ok bool) {
+	if !strings.HasPrefix(header, "bytes=") {
+		return 0, 0, false
+	}
+	bounds := strings.SplitN(strings.TrimPrefix(header, "bytes="), "-", 2)
+	if len(bounds) != 2 {
+		return 0, 0, false
+	}
+	start, err := strconv.Atoi(bounds[0])
+	if err != nil {
+		return 0, 0, false
+	}
+	end = len(testStream) - 1
+	if bounds[1] != "" {
+		if end, err = strconv.Atoi(bounds[1]); err != nil {
+			return 0, 0, false
+		}
+	}
+	return start, end, true
+}
+
+func videoInfoBody() string {
+	playerResponse, _ := json.Marshal(map[string]interface{}{
+		"playabilityStatus": map[string]interface{}{"status": "OK"},
+		"videoDetails":      map[string]interface{}{"videoId": testVideoID, "title": "Artist - Song", "author": "Artist"},
+		"streamingData": map[string]interface{}{
+			"expiresInSeconds": "21540",
+			"formats": []map[string]interface{}{{
+				"itag":          18,
+				"url":           testStreamURL,
+				"mimeType":      `video/mp4; codecs="avc1.42001E, mp4a.40.2"`,
+				"contentLength": strconv.Itoa(len(testStream)),
+			}},
+		},
+	})
+	return url.Values{"status": {"ok"}, "player_response": {string(playerResponse)}}.Encode()
+}
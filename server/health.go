@@ -0,0 +1,70 @@
+package server
+
+import (
+	"errors"
+	"github.com/bit-twit/yt-dl-go/downloader"
+	"io/ioutil"
+	"net/http"
+	"os"
+)
+
+var errNotStarted = errors.New("workers are not running")
+
+// check is a dependency of the server verified by the health endpoints
+type check struct {
+	name string
+	fn   func() error
+}
+
+// healthReport is the body of the health endpoints, checks are "ok" or the error
+type healthReport struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+func (s *Server) healthChecks() []check {
+	converter := s.jobs.dl.Converter
+	if converter == nil {
+		converter = &downloader.Converter{}
+	}
+	return []check{
+		{"ffmpeg", converter.Available},
+		{"outputDir", func() error { return checkWritable(s.outputDir) }},
+	}
+}
+
+// checkWritable creates and removes a file in dir
+func checkWritable(dir string) error {
+	f, err := ioutil.TempFile(dir, ".healthcheck-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// handleHealth answers 200 when ffmpeg is available and the output dir is writable, 503 otherwise
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	s.writeHealth(w, s.healthChecks())
+}
+
+// handleReady also requires the workers to accept jobs
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	checks := append(s.healthChecks(), check{"jobs", s.jobs.ready})
+	s.writeHealth(w, checks)
+}
+
+func (s *Server) writeHealth(w http.ResponseWriter, checks []check) {
+	report := healthReport{Status: "ok", Checks: map[string]string{}}
+	status := http.StatusOK
+	for _, c := range checks {
+		if err := c.fn(); err != nil {
+			report.Checks[c.name] = err.Error()
+			report.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		report.Checks[c.name] = "ok"
+	}
+	writeJSON(w, status, report)
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bit-twit/yt-dl-go/downloader"
+	"github.com/bit-twit/yt-dl-go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getHealth(t *testing.T, url string) (int, healthReport) {
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var report healthReport
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+	return resp.StatusCode, report
+}
+
+func TestServer_Health(t *testing.T) {
+	outputDir := t.TempDir()
+	dl := downloader.NewDownloader(outputDir)
+	// any executable stands in for ffmpeg
+	dl.Converter = &downloader.Converter{FFmpegPath: os.Args[0]}
+	jobs := NewManager(dl, 1, types.FormatConfig{})
+	srv := httptest.NewServer(New(jobs, outputDir, []User{{Name: "alice", Token: "alice-token"}}))
+	defer srv.Close()
+
+	// the health endpoints don't require authentication
+	status, report := getHealth(t, srv.URL+"/healthz")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, map[string]string{"ffmpeg": "ok", "outputDir": "ok"}, report.Checks)
+
+	status, report = getHealth(t, srv.URL+"/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, errNotStarted.Error(), report.Checks["jobs"])
+
+	ctx, cancel := context.WithCancel(context.Background())
+	jobs.Start(ctx)
+	status, _ = getHealth(t, srv.URL+"/readyz")
+	assert.Equal(t, http.StatusOK, status)
+	cancel()
+	status, _ = getHealth(t, srv.URL+"/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+
+	dl.Converter.FFmpegPath = filepath.Join(outputDir, "missing-ffmpeg")
+	status, report = getHealth(t, srv.URL+"/healthz")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "unavailable", report.Status)
+	assert.NotEqual(t, "ok", report.Checks["ffmpeg"])
+	assert.Equal(t, "ok", report.Checks["outputDir"])
+}
+
+func TestServer_HealthOutputDirNotWritable(t *testing.T) {
+	outputDir := filepath.Join(t.TempDir(), "missing")
+	srv := httptest.NewServer(New(NewManager(downloader.NewDownloader(outputDir), 1, types.FormatConfig{}), outputDir, nil))
+	defer srv.Close()
+
+	status, report := getHealth(t, srv.URL+"/healthz")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.NotEqual(t, "ok", report.Checks["outputDir"])
+}
+
+func TestServer_Metrics(t *testing.T) {
+	srv, _ := newTestServerWithUsers(t, &fakeYoutube{}, []User{{Name: "alice", Token: "alice-token"}})
+
+	before := downloadedBytesTotal(t, srv)
+	resp, job := submitAs(t, srv, bearer("alice-token"))
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	waitForStatusAs(t, srv, job.ID, JobDone, bearer("alice-token"))
+
+	// the canceled jobs of other tests may still count their bytes
+	assert.GreaterOrEqual(t, downloadedBytesTotal(t, srv)-before, float64(len(testStream)))
+
+	metrics := scrape(t, srv)
+	for _, name := range []string{
+		`yt_dl_go_http_requests_total{status="200"}`,
+		"yt_dl_go_http_request_duration_seconds_count",
+		"yt_dl_go_job_queue_depth 0",
+		`yt_dl_go_jobs_finished_total{status="done"}`,
+		"# TYPE yt_dl_go_ffmpeg_duration_seconds histogram",
+		"# TYPE yt_dl_go_decipher_cache_requests_total counter",
+		`yt_dl_go_ffmpeg_conversions_total{result="failure"}`,
+		"go_goroutines",
+	} {
+		assert.Contains(t, metrics, name)
+	}
+}
+
+// scrape reads the metrics, they don't require authentication
+func scrape(t *testing.T, srv *httptest.Server) string {
+	resp, err := http.Get(srv.URL + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/plain")
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func downloadedBytesTotal(t *testing.T, srv *httptest.Server) float64 {
+	for _, line := range strings.Split(scrape(t, srv), "\n") {
+		var value float64
+		if n, _ := fmt.Sscanf(line, "yt_dl_go_downloaded_bytes_total %g", &value); n == 1 {
+			return value
+		}
+	}
+	t.Fatal("yt_dl_go_downloaded_bytes_total not found")
+	return 0
+}
@@ -0,0 +1,557 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/bit-twit/yt-dl-go/downloader"
+	"github.com/bit-twit/yt-dl-go/types"
+	"github.com/bit-twit/yt-dl-go/utils"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultQueueSize = 1000
+	// the finished jobs are kept for a day, at most the 1000 last ones
+	defaultFinishedJobsTTL = 24 * time.Hour
+	defaultMaxFinishedJobs = 1000
+)
+
+var (
+	ErrJobNotFound      = errors.New("job not found")
+	ErrJobFinished      = errors.New("job already finished")
+	ErrQueueFull        = errors.New("job queue is full")
+	ErrInvalidOutputDir = errors.New("output file must be a relative path inside the output directory")
+	ErrQuotaExceeded    = errors.New("quota exceeded")
+)
+
+type JobStatus string
+
+const (
+	JobQueued   JobStatus = "queued"
+	JobRunning  JobStatus = "running"
+	JobDone     JobStatus = "done"
+	JobFailed   JobStatus = "failed"
+	JobCanceled JobStatus = "canceled"
+)
+
+// JobRequest is a download requested to the server
+type JobRequest struct {
+	URL        string              `json:"url"`                  // video url or id
+	Format     *types.FormatConfig `json:"format,omitempty"`     // defaults to the server format preferences
+	OutputFile string              `json:"outputFile,omitempty"` // relative to the output directory, built from the title by default
+}
+
+// Job is a download processed by the server workers
+type Job struct {
+	ID          string     `json:"id"`
+	Request     JobRequest `json:"request"`
+	SubmittedBy string     `json:"submittedBy,omitempty"` // name of the user, empty without authentication
+	VideoID     string     `json:"videoId"`
+	Title       string     `json:"title,omitempty"`
+	Status      JobStatus  `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	File        string     `json:"file,omitempty"`    // relative to the output directory
+	Skipped     bool       `json:"skipped,omitempty"` // done without download, the video is in the archive
+	CreatedAt   time.Time  `json:"createdAt"`
+	StartedAt   *time.Time `json:"startedAt,omitempty"`
+	FinishedAt  *time.Time `json:"finishedAt,omitempty"`
+
+	Progress *downloader.Progress `json:"progress,omitempty"` // latest progress of the download
+
+	cancel       context.CancelFunc
+	quota        Quota
+	bytesWritten int64 // downloaded by the job, counted in the daily quota of its user
+	resumedFrom  int64 // bytes of the part file resumed by the job, downloaded before and not counted
+	quotaErr     error // set when the job is stopped for exceeding the quota
+}
+
+func (j *Job) finished() bool {
+	return j.Status == JobDone || j.Status == JobFailed || j.Status == JobCanceled
+}
+
+// Manager queues the download jobs and runs them on a pool of workers
+type Manager struct {
+	dl      *downloader.Downloader
+	format  types.FormatConfig
+	workers int
+	queue   chan *Job
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	ctx     context.Context
+	started bool
+	running sync.WaitGroup
+
+	// subscribers are notified of the changes of a job, see Subscribe
+	subscribers map[string]map[chan struct{}]bool
+	// usage are the bytes downloaded today by the finished jobs of each user
+	usage map[string]dailyUsage
+	// runningBytes are the bytes downloaded by the running jobs of each user
+	runningBytes map[string]int64
+	// activeJobs are the queued and running jobs of each user
+	activeJobs map[string]int
+
+	// finished lists the finished jobs by finish time, the oldest are removed from jobs
+	finished        []*Job
+	finishedJobsTTL time.Duration
+	maxFinishedJobs int
+}
+
+type dailyUsage struct {
+	day   string
+	bytes int64
+}
+
+// NewManager creates a manager downloading with dl, format is used for the jobs without format preferences
+func NewManager(dl *downloader.Downloader, workers int, format types.FormatConfig) *Manager {
+	if workers <= 0 {
+		workers = 1
+	}
+	return &Manager{
+		dl:      dl,
+		format:  format,
+		workers: workers,
+		queue:   make(chan *Job, defaultQueueSize),
+		jobs:    map[string]*Job{},
+		ctx:     context.Background(),
+
+		subscribers:  map[string]map[chan struct{}]bool{},
+		usage:        map[string]dailyUsage{},
+		runningBytes: map[string]int64{},
+		activeJobs:   map[string]int{},
+
+		finishedJobsTTL: defaultFinishedJobsTTL,
+		maxFinishedJobs: defaultMaxFinishedJobs,
+	}
+}
+
+// Start runs the workers until the context is done, running jobs are canceled with it
+func (m *Manager) Start(ctx context.Context) {
+	m.mu.Lock()
+	m.ctx = ctx
+	m.started = true
+	m.mu.Unlock()
+
+	for i := 0; i < m.workers; i++ {
+		m.running.Add(1)
+		go m.work(ctx)
+	}
+}
+
+// Wait blocks until the workers are stopped by the context given to Start
+func (m *Manager) Wait() {
+	m.running.Wait()
+}
+
+func (m *Manager) work(ctx context.Context) {
+	defer m.running.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-m.queue:
+			queueDepth.Set(float64(len(m.queue)))
+			m.run(job)
+		}
+	}
+}
+
+// Submit validates the request and queues a new job for the user, nil when the server doesn't require authentication
+func (m *Manager) Submit(req JobRequest, user *User) (Job, error) {
+	videoID, err := types.ExtractVideoID(req.URL)
+	if err != nil {
+		return Job{}, err
+	}
+	if req.OutputFile != "" {
+		clean := filepath.Clean(req.OutputFile)
+		if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+			return Job{}, ErrInvalidOutputDir
+		}
+		req.OutputFile = clean
+	}
+
+	job := &Job{
+		ID:        newJobID(),
+		Request:   req,
+		VideoID:   videoID,
+		Status:    JobQueued,
+		CreatedAt: time.Now(),
+	}
+	if user != nil {
+		job.SubmittedBy = user.Name
+		job.quota = user.Quota
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune(time.Now())
+	if err := m.checkQuota(job); err != nil {
+		return Job{}, err
+	}
+	select {
+	case m.queue <- job:
+		queueDepth.Set(float64(len(m.queue)))
+	default:
+		return Job{}, ErrQueueFull
+	}
+	m.jobs[job.ID] = job
+	m.activeJobs[job.SubmittedBy]++
+	return *job, nil
+}
+
+// Get returns a snapshot of the job
+func (m *Manager) Get(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+	return *job, nil
+}
+
+// List returns a snapshot of all the jobs, oldest first
+func (m *Manager) List() []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := make([]Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobs = append(jobs, *job)
+	}
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].CreatedAt.Before(jobs[k].CreatedAt) })
+	return jobs
+}
+
+// Cancel stops a queued or running job
+func (m *Manager) Cancel(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+	if job.finished() {
+		return *job, ErrJobFinished
+	}
+
+	if job.cancel != nil {
+		job.cancel()
+	}
+	m.finish(job, JobCanceled)
+	m.notify(job.ID)
+	return *job, nil
+}
+
+// finish sets the final status of the job and removes the oldest finished jobs, m.mu must be held
+func (m *Manager) finish(job *Job, status JobStatus) {
+	job.Status = status
+	now := time.Now()
+	job.FinishedAt = &now
+	jobsFinished.WithLabelValues(string(status)).Inc()
+	if m.activeJobs[job.SubmittedBy]--; m.activeJobs[job.SubmittedBy] <= 0 {
+		delete(m.activeJobs, job.SubmittedBy)
+	}
+	m.finished = append(m.finished, job)
+	m.prune(now)
+}
+
+// prune removes the jobs finished for longer than finishedJobsTTL and the oldest ones beyond maxFinishedJobs,
+// m.mu must be held
+func (m *Manager) prune(now time.Time) {
+	for len(m.finished) > 0 {
+		oldest := m.finished[0]
+		if len(m.finished) <= m.maxFinishedJobs && now.Sub(*oldest.FinishedAt) < m.finishedJobsTTL {
+			return
+		}
+		delete(m.jobs, oldest.ID)
+		m.finished[0] = nil
+		m.finished = m.finished[1:]
+	}
+}
+
+// ready tells if the workers are running and the queue accepts jobs
+func (m *Manager) ready() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case !m.started:
+		return errNotStarted
+	case m.ctx.Err() != nil:
+		return m.ctx.Err()
+	case len(m.queue) == cap(m.queue):
+		return ErrQueueFull
+	}
+	return nil
+}
+
+// Subscribe returns a channel receiving a value when the job changes and a func to stop the subscription.
+// Notifications are coalesced, the subscriber reads the current state of the job with Get.
+func (m *Manager) Subscribe(id string) (<-chan struct{}, func(), error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.jobs[id]; !ok {
+		return nil, nil, ErrJobNotFound
+	}
+
+	ch := make(chan struct{}, 1)
+	if m.subscribers[id] == nil {
+		m.subscribers[id] = map[chan struct{}]bool{}
+	}
+	m.subscribers[id][ch] = true
+	unsubscribe := func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.subscribers[id], ch)
+		if len(m.subscribers[id]) == 0 {
+			delete(m.subscribers, id)
+		}
+	}
+	return ch, unsubscribe, nil
+}
+
+// notify signals a change of the job to its subscribers, m.mu must be held
+func (m *Manager) notify(id string) {
+	for ch := range m.subscribers[id] {
+		select {
+		case ch <- struct{}{}:
+		default:
+			// a notification is already pending
+		}
+	}
+}
+
+// jobListener reports the download events of a job to the manager
+type jobListener struct {
+	m   *Manager
+	job *Job
+}
+
+func (l jobListener) Start(p downloader.Progress) {
+	l.m.mu.Lock()
+	l.job.resumedFrom = p.BytesWritten
+	l.m.mu.Unlock()
+	l.m.setProgress(l.job, p, true)
+}
+
+func (l jobListener) Progress(p downloader.Progress) { l.m.setProgress(l.job, p, true) }
+
+// Finish only updates the progress, the size of the complete file includes the resumed bytes
+func (l jobListener) Finish(p downloader.Progress, file string) { l.m.setProgress(l.job, p, false) }
+
+func (l jobListener) Fail(p downloader.Progress, err error) {}
+
+// setProgress records the latest progress of a running job. When counted, the bytes written since the start of
+// the job are counted in the daily quota, the job is stopped when its user exceeds it.
+func (m *Manager) setProgress(job *Job, p downloader.Progress, counted bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if job.Status != JobRunning {
+		return
+	}
+	job.Progress = &p
+	if counted && p.Phase == downloader.PhaseDownloading {
+		written := p.BytesWritten - job.resumedFrom
+		m.runningBytes[job.SubmittedBy] += written - job.bytesWritten
+		job.bytesWritten = written
+		if max := job.quota.MaxBytesPerDay; max > 0 && job.quotaErr == nil && m.usedBytes(job.SubmittedBy) > max {
+			job.quotaErr = fmt.Errorf("%w: %s downloaded today", ErrQuotaExceeded, utils.FormatBytes(max))
+			job.cancel()
+		}
+	}
+	m.notify(job.ID)
+}
+
+// checkQuota tells if the user of a new job can submit it, m.mu must be held
+func (m *Manager) checkQuota(job *Job) error {
+	if max := job.quota.MaxJobs; max > 0 {
+		if active := m.activeJobs[job.SubmittedBy]; active >= max {
+			return fmt.Errorf("%w: %d jobs already queued or running", ErrQuotaExceeded, active)
+		}
+	}
+	if max := job.quota.MaxBytesPerDay; max > 0 && m.usedBytes(job.SubmittedBy) >= max {
+		return fmt.Errorf("%w: %s downloaded today", ErrQuotaExceeded, utils.FormatBytes(max))
+	}
+	return nil
+}
+
+// checkSize tells if a download of size bytes fits in the remaining daily quota of the job user
+func (m *Manager) checkSize(job *Job, size int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	max := job.quota.MaxBytesPerDay
+	if max <= 0 {
+		return nil
+	}
+	if remaining := max - m.usedBytes(job.SubmittedBy); size > remaining {
+		return fmt.Errorf("%w: %s left today, the format is %s", ErrQuotaExceeded,
+			utils.FormatBytes(remaining), utils.FormatBytes(size))
+	}
+	return nil
+}
+
+// usedBytes returns the bytes downloaded today by the user, including its running jobs, m.mu must be held
+func (m *Manager) usedBytes(user string) int64 {
+	used := m.runningBytes[user]
+	if u := m.usage[user]; u.day == today() {
+		used += u.bytes
+	}
+	return used
+}
+
+// addUsage moves the bytes of a finished job from the running bytes to the daily usage of its user, m.mu must be held
+func (m *Manager) addUsage(job *Job) {
+	if m.runningBytes[job.SubmittedBy] -= job.bytesWritten; m.runningBytes[job.SubmittedBy] <= 0 {
+		delete(m.runningBytes, job.SubmittedBy)
+	}
+	m.addDailyUsage(job.SubmittedBy, job.bytesWritten)
+}
+
+// addDailyUsage counts bytes in the usage of the user for today, m.mu must be held
+func (m *Manager) addDailyUsage(user string, bytes int64) {
+	u := m.usage[user]
+	if day := today(); u.day != day {
+		u = dailyUsage{day: day}
+	}
+	u.bytes += bytes
+	m.usage[user] = u
+}
+
+// checkStreamQuota tells if the user has bytes left today to proxy a stream
+func (m *Manager) checkStreamQuota(user *User) error {
+	if user == nil || user.Quota.MaxBytesPerDay <= 0 {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if max := user.Quota.MaxBytesPerDay; m.usedBytes(user.Name) >= max {
+		return fmt.Errorf("%w: %s downloaded today", ErrQuotaExceeded, utils.FormatBytes(max))
+	}
+	return nil
+}
+
+// addStreamBytes counts the bytes proxied to the user in its daily usage
+func (m *Manager) addStreamBytes(user *User, bytes int64) {
+	if user == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.addDailyUsage(user.Name, bytes)
+}
+
+func today() string {
+	return time.Now().Format("2006-01-02")
+}
+
+func (m *Manager) run(job *Job) {
+	m.mu.Lock()
+	if job.Status != JobQueued {
+		// canceled while waiting in the queue
+		m.mu.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(m.ctx)
+	defer cancel()
+	job.cancel = cancel
+	job.Status = JobRunning
+	now := time.Now()
+	job.StartedAt = &now
+	m.notify(job.ID)
+	m.mu.Unlock()
+
+	ctx = downloader.WithProgressListener(ctx, jobListener{m: m, job: job})
+
+	title, file, err := m.download(ctx, job)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	defer m.notify(job.ID)
+	m.addUsage(job)
+	job.Title = title
+	job.cancel = nil
+	if job.Status == JobCanceled {
+		return
+	}
+	switch {
+	case job.quotaErr != nil:
+		job.Error = job.quotaErr.Error()
+		m.finish(job, JobFailed)
+	case ctx.Err() != nil:
+		m.finish(job, JobCanceled)
+	case errors.Is(err, types.ErrArchived):
+		job.Skipped = true
+		job.File = file
+		m.finish(job, JobDone)
+	case err != nil:
+		job.Error = err.Error()
+		log.Printf("job %s failed: %v", job.ID, err)
+		m.finish(job, JobFailed)
+	default:
+		job.File = file
+		m.finish(job, JobDone)
+	}
+}
+
+// download runs the job download and returns the video title and the file relative to the output dir
+func (m *Manager) download(ctx context.Context, job *Job) (string, string, error) {
+	v, err := m.dl.GetVideoInfo(ctx, job.VideoID)
+	if err != nil {
+		return "", "", err
+	}
+
+	pref := m.format
+	if job.Request.Format != nil {
+		pref = *job.Request.Format
+	}
+
+	format := v.Formats.Select(pref)
+	if pref.AudioOnly {
+		// the mp3 is converted from the m4a audio stream
+		format = v.Formats.FindByItag(140)
+	}
+	if format == nil {
+		return v.Title, "", fmt.Errorf("%w: %+v", types.ErrFormatNotFound, pref)
+	}
+	if err := m.checkSize(job, format.Size()); err != nil {
+		return v.Title, "", err
+	}
+
+	var file string
+	if pref.AudioOnly {
+		file, err = m.dl.DownloadMP3Context(ctx, v)
+	} else {
+		file, err = m.dl.DownloadContext(ctx, v, format, job.Request.OutputFile)
+	}
+	if errors.Is(err, types.ErrArchived) {
+		// downloaded before, the job points at the file when it wasn't moved or removed since
+		plan := m.dl.PlanDownload(v, format, job.Request.OutputFile)
+		if pref.AudioOnly {
+			plan, _ = m.dl.PlanDownloadMP3(v)
+		}
+		if _, statErr := os.Stat(plan.FinalFile); statErr != nil {
+			return v.Title, "", err
+		}
+		file = plan.FinalFile
+	} else if err != nil {
+		return v.Title, "", err
+	}
+
+	if rel, err := filepath.Rel(m.dl.OutputDir, file); err == nil {
+		file = rel
+	}
+	return v.Title, filepath.ToSlash(file), err
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,17 @@
+package server
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	queueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "yt_dl_go_job_queue_depth",
+		Help: "Jobs waiting for a worker.",
+	})
+	jobsFinished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "yt_dl_go_jobs_finished_total",
+		Help: "Finished jobs by status: done, failed or canceled.",
+	}, []string{"status"})
+)
@@ -0,0 +1,339 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/bit-twit/yt-dl-go/types"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Server exposes the REST job API, the downloaded files and a web UI using them:
+//
+//	GET    /                  the web UI
+//	GET    /info?url=         get a video and its formats, without the stream urls
+//	POST   /jobs              queue a download, the body is a JobRequest
+//	GET    /jobs              list the jobs of the user, of every user for the admins
+//	GET    /jobs/{id}         get a job status
+//	DELETE /jobs/{id}         cancel a queued or running job
+//	GET    /jobs/{id}/events  stream the job status and progress as server-sent events
+//	GET    /files/...         download the files of the output directory
+//	GET    /stream/{videoID}  proxy the stream of the format given by the itag query param, honoring Range
+//
+// The monitoring routes don't require authentication:
+//
+//	GET    /metrics           Prometheus metrics
+//	GET    /healthz           503 when ffmpeg is missing or the output directory isn't writable
+//	GET    /readyz            like /healthz, also 503 when the workers don't accept jobs
+//
+// When users are given, the other routes require a bearer token or basic auth credentials, the jobs of the other
+// users are only visible to the admins and the proxied streams count in the daily quota of the user.
+type Server struct {
+	jobs      *Manager
+	outputDir string
+	users     []User
+	mux       *http.ServeMux
+	handler   http.Handler
+}
+
+func New(jobs *Manager, outputDir string, users []User) *Server {
+	s := &Server{
+		jobs:      jobs,
+		outputDir: outputDir,
+		users:     users,
+		mux:       http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/jobs", s.handleJobs)
+	s.mux.HandleFunc("/jobs/", s.handleJob)
+	s.mux.Handle("/files/", http.StripPrefix("/files/", http.FileServer(http.Dir(outputDir))))
+	s.mux.HandleFunc("/stream/", s.handleStream)
+	s.mux.HandleFunc("/info", s.handleInfo)
+	s.mux.Handle("/", webHandler())
+
+	root := http.NewServeMux()
+	root.Handle("/metrics", promhttp.Handler())
+	root.HandleFunc("/healthz", s.handleHealth)
+	root.HandleFunc("/readyz", s.handleReady)
+	root.Handle("/", s.authenticate(s.mux))
+	s.handler = root
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		user := userFromRequest(r)
+		jobs := []Job{}
+		for _, job := range s.jobs.List() {
+			if canAccess(user, job) {
+				jobs = append(jobs, job)
+			}
+		}
+		writeJSON(w, http.StatusOK, jobs)
+	case http.MethodPost:
+		var req JobRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		job, err := s.jobs.Submit(req, userFromRequest(r))
+		if errors.Is(err, ErrQueueFull) {
+			writeError(w, http.StatusServiceUnavailable, err)
+			return
+		}
+		if errors.Is(err, ErrQuotaExceeded) {
+			writeError(w, http.StatusTooManyRequests, err)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		w.Header().Set("Location", "/jobs/"+job.ID)
+		writeJSON(w, http.StatusAccepted, job)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+func (s *Server) handleJob(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/jobs/")
+	if strings.HasSuffix(id, "/events") {
+		s.handleJobEvents(w, r, strings.TrimSuffix(id, "/events"))
+		return
+	}
+	if id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	job, err := s.getJob(r, id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, job)
+	case http.MethodDelete:
+		job, err := s.jobs.Cancel(id)
+		switch {
+		case errors.Is(err, ErrJobNotFound):
+			writeError(w, http.StatusNotFound, err)
+		case errors.Is(err, ErrJobFinished):
+			writeError(w, http.StatusConflict, err)
+		default:
+			writeJSON(w, http.StatusOK, job)
+		}
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodDelete)
+	}
+}
+
+// getJob returns the job when the user of the request can see it, the jobs of the other users are not found
+func (s *Server) getJob(r *http.Request, id string) (Job, error) {
+	job, err := s.jobs.Get(id)
+	if err != nil {
+		return Job{}, err
+	}
+	if !canAccess(userFromRequest(r), job) {
+		return Job{}, ErrJobNotFound
+	}
+	return job, nil
+}
+
+// handleJobEvents streams a "status" event with the job when its status changes and a "progress" event
+// with the download progress, the stream ends when the job is finished
+func (s *Server) handleJobEvents(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming not supported"))
+		return
+	}
+	if _, err := s.getJob(r, id); err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	updates, unsubscribe, err := s.jobs.Subscribe(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	var last *Job
+	for {
+		job, err := s.jobs.Get(id)
+		if err != nil {
+			return
+		}
+		if last == nil || job.Status != last.Status {
+			writeEvent(w, "status", job)
+		}
+		if job.Progress != nil && (last == nil || job.Progress != last.Progress) {
+			writeEvent(w, "progress", job.Progress)
+		}
+		flusher.Flush()
+		if job.finished() {
+			return
+		}
+		last = &job
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-updates:
+		}
+	}
+}
+
+// VideoInfo describes a video and its formats without their stream urls
+type VideoInfo struct {
+	ID       string             `json:"id"`
+	Title    string             `json:"title"`
+	Author   string             `json:"author,omitempty"`
+	Duration float64            `json:"duration,omitempty"` // seconds
+	Formats  []types.FormatInfo `json:"formats"`
+}
+
+func (s *Server) handleInfo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	videoID, err := types.ExtractVideoID(r.URL.Query().Get("url"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	v, err := s.jobs.dl.GetVideoInfo(r.Context(), videoID)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, VideoInfo{
+		ID:       v.ID,
+		Title:    v.Title,
+		Author:   v.Author,
+		Duration: v.Duration.Seconds(),
+		Formats:  v.Formats.Infos(),
+	})
+}
+
+// streamHeaders are passed from the youtube stream response to the client
+var streamHeaders = []string{"Content-Type", "Content-Length", "Content-Range", "Accept-Ranges", "Last-Modified"}
+
+// handleStream proxies a format stream to the client without writing it to disk nor exposing the stream url
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(w, http.MethodGet, http.MethodHead)
+		return
+	}
+	videoID, err := types.ExtractVideoID(strings.TrimPrefix(r.URL.Path, "/stream/"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	itag, err := strconv.Atoi(r.URL.Query().Get("itag"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid itag %q", r.URL.Query().Get("itag")))
+		return
+	}
+
+	user := userFromRequest(r)
+	if err := s.jobs.checkStreamQuota(user); err != nil {
+		writeError(w, http.StatusTooManyRequests, err)
+		return
+	}
+
+	dl := s.jobs.dl
+	v, err := dl.GetVideoInfo(r.Context(), videoID)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	format := v.Formats.FindByItag(itag)
+	if format == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("%w: itag %d", types.ErrFormatNotFound, itag))
+		return
+	}
+
+	resp, err := dl.OpenStream(r.Context(), v, format, r.Header.Get("Range"))
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	for _, h := range streamHeaders {
+		if value := resp.Header.Get(h); value != "" {
+			w.Header().Set(h, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := io.Copy(quotaWriter{w: w, m: s.jobs, user: user}, resp.Body); err != nil && r.Context().Err() == nil {
+		log.Printf("stream %s itag %d interrupted: %v", videoID, itag, err)
+	}
+}
+
+// quotaWriter counts the bytes proxied to the user in its daily usage and stops the stream once the quota is used up
+type quotaWriter struct {
+	w    io.Writer
+	m    *Manager
+	user *User
+}
+
+func (q quotaWriter) Write(p []byte) (int, error) {
+	if err := q.m.checkStreamQuota(q.user); err != nil {
+		return 0, err
+	}
+	n, err := q.w.Write(p)
+	q.m.addStreamBytes(q.user, int64(n))
+	return n, err
+}
+
+func writeEvent(w http.ResponseWriter, event string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("unable to write event: %v", err)
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("unable to write response: %v", err)
+	}
+}
@@ -0,0 +1,378 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bit-twit/yt-dl-go/downloader"
+	"github.com/bit-twit/yt-dl-go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T, yt *fakeYoutube) (*httptest.Server, string) {
+	return newTestServerWithUsers(t, yt, nil)
+}
+
+func newTestServerWithUsers(t *testing.T, yt *fakeYoutube, users []User) (*httptest.Server, string) {
+	dl := downloader.NewDownloader(t.TempDir())
+	dl.HTTPClient = &http.Client{Transport: yt}
+	return newTestServerWithDownloader(t, dl, users), dl.OutputDir
+}
+
+func newTestServerWithDownloader(t *testing.T, dl *downloader.Downloader, users []User) *httptest.Server {
+	outputDir := dl.OutputDir
+	ctx, cancel := context.WithCancel(context.Background())
+	jobs := NewManager(dl, 2, types.FormatConfig{MimeType: "video/mp4"})
+	jobs.Start(ctx)
+	t.Cleanup(func() {
+		cancel()
+		// the output dir is removed once the jobs are stopped
+		jobs.Wait()
+	})
+
+	srv := httptest.NewServer(New(jobs, outputDir, users))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func postJob(t *testing.T, srv *httptest.Server, req JobRequest) (*http.Response, Job) {
+	body, _ := json.Marshal(req)
+	resp, err := http.Post(srv.URL+"/jobs", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var job Job
+	json.NewDecoder(resp.Body).Decode(&job)
+	return resp, job
+}
+
+func getJob(t *testing.T, srv *httptest.Server, id string) Job {
+	resp, err := http.Get(srv.URL + "/jobs/" + id)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var job Job
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&job))
+	return job
+}
+
+func waitForStatus(t *testing.T, srv *httptest.Server, id string, status JobStatus) Job {
+	var job Job
+	require.Eventually(t, func() bool {
+		job = getJob(t, srv, id)
+		return job.Status == status
+	}, 5*time.Second, 10*time.Millisecond)
+	return job
+}
+
+func TestServer_JobLifecycle(t *testing.T) {
+	srv, outputDir := newTestServer(t, &fakeYoutube{})
+
+	resp, job := postJob(t, srv, JobRequest{URL: "https://youtu.be/" + testVideoID})
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Equal(t, "/jobs/"+job.ID, resp.Header.Get("Location"))
+	assert.Equal(t, testVideoID, job.VideoID)
+
+	job = waitForStatus(t, srv, job.ID, JobDone)
+	assert.Equal(t, "Artist - Song", job.Title)
+	assert.Equal(t, "Artist/Song.mp4", job.File)
+	assert.NotNil(t, job.FinishedAt)
+
+	content, err := ioutil.ReadFile(filepath.Join(outputDir, "Artist", "Song.mp4"))
+	require.NoError(t, err)
+	assert.Equal(t, testStream, content)
+
+	fileResp, err := http.Get(srv.URL + "/files/" + job.File)
+	require.NoError(t, err)
+	defer fileResp.Body.Close()
+	served, _ := ioutil.ReadAll(fileResp.Body)
+	assert.Equal(t, testStream, served)
+
+	listResp, err := http.Get(srv.URL + "/jobs")
+	require.NoError(t, err)
+	defer listResp.Body.Close()
+	var jobs []Job
+	require.NoError(t, json.NewDecoder(listResp.Body).Decode(&jobs))
+	require.Len(t, jobs, 1)
+	assert.Equal(t, job.ID, jobs[0].ID)
+}
+
+func TestServer_JobArchive(t *testing.T) {
+	dl := downloader.NewDownloader(t.TempDir())
+	dl.HTTPClient = &http.Client{Transport: &fakeYoutube{}}
+	archive, err := downloader.OpenArchive(filepath.Join(t.TempDir(), "archive.txt"))
+	require.NoError(t, err)
+	dl.Archive = archive
+	srv := newTestServerWithDownloader(t, dl, nil)
+
+	_, job := postJob(t, srv, JobRequest{URL: testVideoID})
+	first := waitForStatus(t, srv, job.ID, JobDone)
+	assert.False(t, first.Skipped)
+	assert.True(t, archive.Has(testVideoID, "18"))
+
+	// the archived video points at the file of the first download
+	_, job = postJob(t, srv, JobRequest{URL: testVideoID})
+	job = waitForStatus(t, srv, job.ID, JobDone)
+	assert.True(t, job.Skipped)
+	assert.Empty(t, job.Error)
+	assert.Equal(t, first.File, job.File)
+
+	_, job = postJob(t, srv, JobRequest{URL: testVideoID, OutputFile: "again.mp4"})
+	job = waitForStatus(t, srv, job.ID, JobDone)
+	assert.True(t, job.Skipped)
+	assert.Empty(t, job.File, "nothing written to again.mp4")
+	assert.NoFileExists(t, filepath.Join(dl.OutputDir, "again.mp4"))
+}
+
+func TestServer_SubmitValidation(t *testing.T) {
+	srv, _ := newTestServer(t, &fakeYoutube{})
+
+	resp, _ := postJob(t, srv, JobRequest{URL: "https://vimeo.com/123"})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, _ = postJob(t, srv, JobRequest{URL: testVideoID, OutputFile: "../escape.mp4"})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestServer_CancelJob(t *testing.T) {
+	srv, outputDir := newTestServer(t, &fakeYoutube{block: true})
+
+	_, job := postJob(t, srv, JobRequest{URL: testVideoID, OutputFile: "blocked.mp4"})
+	waitForStatus(t, srv, job.ID, JobRunning)
+
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/jobs/"+job.ID, nil)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	job = waitForStatus(t, srv, job.ID, JobCanceled)
+	assert.Empty(t, job.File)
+	assert.Eventually(t, func() bool {
+		_, err := ioutil.ReadFile(filepath.Join(outputDir, "blocked.mp4"))
+		return err != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// finished jobs can't be canceled again
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	req, _ = http.NewRequest(http.MethodDelete, srv.URL+"/jobs/unknown", nil)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+type sseEvent struct {
+	name string
+	data string
+}
+
+// readEvents reads the server-sent events until the stream ends
+func readEvents(t *testing.T, body io.Reader) []sseEvent {
+	var events []sseEvent
+	var event sseEvent
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			event.data = strings.TrimPrefix(line, "data: ")
+		case line == "":
+			events = append(events, event)
+			event = sseEvent{}
+		}
+	}
+	require.NoError(t, scanner.Err())
+	return events
+}
+
+func TestServer_JobEvents(t *testing.T) {
+	srv, _ := newTestServer(t, &fakeYoutube{block: true})
+
+	_, job := postJob(t, srv, JobRequest{URL: testVideoID, OutputFile: "events.mp4"})
+
+	resp, err := http.Get(srv.URL + "/jobs/" + job.ID + "/events")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	// cancel once the first bytes are reported, which ends the stream
+	go func() {
+		assert.Eventually(t, func() bool {
+			p := getJob(t, srv, job.ID).Progress
+			return p != nil && p.Phase == downloader.PhaseDownloading
+		}, 5*time.Second, 10*time.Millisecond)
+		req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/jobs/"+job.ID, nil)
+		if resp, err := http.DefaultClient.Do(req); err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	events := readEvents(t, resp.Body)
+	require.NotEmpty(t, events)
+
+	var downloading *downloader.Progress
+	for _, e := range events {
+		if e.name != "progress" {
+			continue
+		}
+		var p downloader.Progress
+		require.NoError(t, json.Unmarshal([]byte(e.data), &p))
+		if p.Phase == downloader.PhaseDownloading {
+			downloading = &p
+		}
+	}
+	require.NotNil(t, downloading)
+	assert.Equal(t, testVideoID, downloading.VideoID)
+	assert.Equal(t, int64(4), downloading.BytesWritten)
+	assert.Equal(t, int64(len(testStream)), downloading.Total)
+
+	last := events[len(events)-1]
+	assert.Equal(t, "status", last.name)
+	var finished Job
+	require.NoError(t, json.Unmarshal([]byte(last.data), &finished))
+	assert.Equal(t, JobCanceled, finished.Status)
+
+	resp, err = http.Get(srv.URL + "/jobs/unknown/events")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestServer_Stream(t *testing.T) {
+	srv, outputDir := newTestServer(t, &fakeYoutube{})
+
+	resp, err := http.Get(srv.URL + "/stream/" + testVideoID + "?itag=18")
+	require.NoError(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, testStream, body)
+	assert.Equal(t, "video/mp4", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "bytes", resp.Header.Get("Accept-Ranges"))
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/stream/"+testVideoID+"?itag=18", nil)
+	req.Header.Set("Range", "bytes=5-8")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	body, _ = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, testStream[5:9], body)
+	assert.Equal(t, fmt.Sprintf("bytes 5-8/%d", len(testStream)), resp.Header.Get("Content-Range"))
+
+	req.Header.Set("Range", "bytes=100-")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, resp.StatusCode)
+
+	// nothing is written to disk
+	files, err := ioutil.ReadDir(outputDir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestServer_StreamErrors(t *testing.T) {
+	srv, _ := newTestServer(t, &fakeYoutube{})
+
+	for path, status := range map[string]int{
+		"/stream/" + testVideoID:             http.StatusBadRequest,
+		"/stream/" + testVideoID + "?itag=x": http.StatusBadRequest,
+		"/stream/bad?itag=18":                http.StatusBadRequest,
+		"/stream/" + testVideoID + "?itag=1": http.StatusNotFound,
+	} {
+		resp, err := http.Get(srv.URL + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, status, resp.StatusCode, path)
+	}
+}
+
+func TestServer_Info(t *testing.T) {
+	srv, _ := newTestServer(t, &fakeYoutube{})
+
+	resp, err := http.Get(srv.URL + "/info?url=" + url.QueryEscape("https://www.youtube.com/watch?v="+testVideoID))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	assert.NotContains(t, string(body), testStreamURL)
+
+	var info VideoInfo
+	require.NoError(t, json.Unmarshal(body, &info))
+	assert.Equal(t, testVideoID, info.ID)
+	assert.Equal(t, "Artist - Song", info.Title)
+	require.Len(t, info.Formats, 1)
+	assert.Equal(t, 18, info.Formats[0].Itag)
+	assert.Equal(t, int64(len(testStream)), info.Formats[0].Size)
+
+	resp, err = http.Get(srv.URL + "/info?url=https://vimeo.com/123")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestServer_WebUI(t *testing.T) {
+	srv, _ := newTestServer(t, &fakeYoutube{})
+
+	for _, path := range []string{"/", "/app.js", "/style.css"} {
+		resp, err := http.Get(srv.URL + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode, path)
+	}
+
+	resp, err := http.Get(srv.URL + "/")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/html")
+}
+
+func TestManager_PruneFinishedJobs(t *testing.T) {
+	m := NewManager(downloader.NewDownloader(t.TempDir()), 1, types.FormatConfig{})
+	m.maxFinishedJobs = 2
+	for _, id := range []string{"a", "b", "c"} {
+		job := &Job{ID: id, SubmittedBy: "alice", Status: JobQueued}
+		m.jobs[id] = job
+		m.activeJobs[job.SubmittedBy]++
+	}
+
+	m.mu.Lock()
+	m.finish(m.jobs["a"], JobDone)
+	m.finish(m.jobs["b"], JobFailed)
+	m.finish(m.jobs["c"], JobCanceled)
+	m.mu.Unlock()
+	assert.Empty(t, m.activeJobs)
+	_, err := m.Get("a")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+	assert.Len(t, m.List(), 2)
+
+	// the jobs finished for longer than the retention are removed
+	m.mu.Lock()
+	m.prune(time.Now().Add(defaultFinishedJobsTTL))
+	m.mu.Unlock()
+	assert.Empty(t, m.List())
+}
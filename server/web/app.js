@@ -0,0 +1,204 @@
+'use strict';
+
+const $ = (id) => document.getElementById(id);
+
+let currentURL = '';
+
+function formatBytes(n) {
+  if (!n) {
+    return '-';
+  }
+  const units = ['B', 'KiB', 'MiB', 'GiB'];
+  let i = 0;
+  while (n >= 1024 && i < units.length - 1) {
+    n /= 1024;
+    i++;
+  }
+  return n.toFixed(1) + units[i];
+}
+
+function formatDuration(seconds) {
+  seconds = Math.round(seconds);
+  const m = Math.floor(seconds / 60);
+  const s = String(seconds % 60).padStart(2, '0');
+  return m + ':' + s;
+}
+
+function showError(err) {
+  $('error').textContent = err ? String(err) : '';
+  $('error').hidden = !err;
+}
+
+async function request(method, path, body) {
+  const options = {method: method, headers: {}};
+  if (body !== undefined) {
+    options.headers['Content-Type'] = 'application/json';
+    options.body = JSON.stringify(body);
+  }
+  const resp = await fetch(path, options);
+  const data = await resp.json();
+  if (!resp.ok) {
+    throw new Error(data.error || resp.statusText);
+  }
+  return data;
+}
+
+async function lookup(event) {
+  event.preventDefault();
+  showError();
+  currentURL = $('url').value.trim();
+  try {
+    const video = await request('GET', 'info?url=' + encodeURIComponent(currentURL));
+    renderVideo(video);
+  } catch (err) {
+    $('video').hidden = true;
+    showError(err.message);
+  }
+}
+
+function renderVideo(video) {
+  $('title').textContent = video.title;
+  $('author').textContent = [video.author, video.duration ? formatDuration(video.duration) : '']
+    .filter(Boolean).join(' · ');
+
+  const rows = $('formats');
+  rows.textContent = '';
+  for (const f of video.formats) {
+    const row = document.createElement('tr');
+    const radio = document.createElement('input');
+    radio.type = 'radio';
+    radio.name = 'format';
+    radio.value = f.itag;
+    const cells = [
+      radio,
+      f.qualityLabel || f.audioQuality || '-',
+      f.kind,
+      f.container,
+      formatBytes(f.size),
+    ];
+    for (const value of cells) {
+      const cell = document.createElement('td');
+      if (value instanceof Node) {
+        cell.appendChild(value);
+      } else {
+        cell.textContent = value;
+      }
+      row.appendChild(cell);
+    }
+    rows.appendChild(row);
+  }
+  $('video').hidden = false;
+}
+
+async function download(event) {
+  event.preventDefault();
+  showError();
+  const choice = new FormData($('download')).get('format');
+  const format = choice === 'mp3' ? {audioOnly: true} : {itag: Number(choice)};
+  try {
+    const job = await request('POST', 'jobs', {url: currentURL, format: format});
+    addJob(job);
+  } catch (err) {
+    showError(err.message);
+  }
+}
+
+function finished(job) {
+  return job.status === 'done' || job.status === 'failed' || job.status === 'canceled';
+}
+
+function renderJob(item, job, progress) {
+  item.textContent = '';
+
+  const title = document.createElement('strong');
+  title.textContent = job.title || job.videoId;
+  item.appendChild(title);
+  if (job.submittedBy) {
+    item.appendChild(document.createTextNode(' by ' + job.submittedBy));
+  }
+
+  const status = document.createElement('div');
+  status.className = 'muted';
+  let text = job.status;
+  if (job.status === 'running' && progress) {
+    text = progress.phase.replace('_', ' ');
+    if (progress.phase === 'downloading') {
+      text += ' ' + formatBytes(progress.bytesWritten);
+      if (progress.total) {
+        text += ' of ' + formatBytes(progress.total);
+      }
+      if (progress.speed) {
+        text += ' at ' + formatBytes(progress.speed) + '/s';
+      }
+      if (progress.eta) {
+        text += ', ' + formatDuration(progress.eta) + ' left';
+      }
+    }
+  }
+  if (job.skipped) {
+    text += ': already in the archive';
+  }
+  if (job.error) {
+    text += ': ' + job.error;
+    status.className = 'error';
+  }
+  status.textContent = text;
+  item.appendChild(status);
+
+  if (job.status === 'running' && progress && progress.phase === 'downloading' && progress.total) {
+    const bar = document.createElement('progress');
+    bar.max = 100;
+    bar.value = progress.percent;
+    item.appendChild(bar);
+  }
+
+  if (job.status === 'queued' || job.status === 'running') {
+    const cancel = document.createElement('button');
+    cancel.textContent = 'Cancel';
+    cancel.onclick = () => request('DELETE', 'jobs/' + job.id).catch((err) => showError(err.message));
+    item.appendChild(cancel);
+  }
+
+  if (job.status === 'done' && job.file) {
+    const link = document.createElement('a');
+    link.href = 'files/' + job.file.split('/').map(encodeURIComponent).join('/');
+    link.textContent = 'Save ' + job.file.split('/').pop();
+    link.download = '';
+    item.appendChild(link);
+  }
+}
+
+function addJob(job) {
+  $('empty').hidden = true;
+  const item = document.createElement('li');
+  $('jobs').prepend(item);
+  renderJob(item, job, job.progress);
+  if (finished(job)) {
+    return;
+  }
+
+  const events = new EventSource('jobs/' + job.id + '/events');
+  events.addEventListener('status', (e) => {
+    job = JSON.parse(e.data);
+    renderJob(item, job, job.progress);
+    if (finished(job)) {
+      events.close();
+    }
+  });
+  events.addEventListener('progress', (e) => renderJob(item, job, JSON.parse(e.data)));
+}
+
+async function loadJobs() {
+  try {
+    const jobs = await request('GET', 'jobs');
+    for (const job of jobs) {
+      addJob(job);
+    }
+  } catch (err) {
+    showError(err.message);
+  }
+}
+
+$('lookup').addEventListener('submit', lookup);
+$('download').addEventListener('submit', download);
+loadJobs();
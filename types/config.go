@@ -0,0 +1,94 @@
+package types
+
+// Config holds the application settings, loaded from the config file, env vars and cli flags
+type Config struct {
+	// API HTTP config options
+	HTTPSEnabled     bool   `yaml:"httpsEnabled"`
+	Host             string `yaml:"host"`
+	Port             int    `yaml:"port"`
+	ApiKey           string `yaml:"apiKey"`
+	ClientSecretPath string `yaml:"clientSecret"`
+
+	OutputDir   string            `yaml:"outputDir"`
+	Format      FormatConfig      `yaml:"format"`
+	Converter   ConverterConfig   `yaml:"converter"`
+	Proxy       string            `yaml:"proxy"`     // proxy URL, by default the HTTP(S)_PROXY env vars are used
+	LimitRate   string            `yaml:"limitRate"` // eg: 2M, or "unlimited 01:00-07:00, 500K otherwise"
+	Concurrency ConcurrencyConfig `yaml:"concurrency"`
+	Retry       RetryConfig       `yaml:"retry"`
+	Archive     string            `yaml:"archive"` // file recording the downloaded videos, which are skipped by the next runs
+	Server      ServerConfig      `yaml:"server"`
+}
+
+// FormatConfig are the preferences used to pick the downloaded format of a video
+type FormatConfig struct {
+	AudioOnly bool   `yaml:"audioOnly" json:"audioOnly,omitempty"` // download the audio stream and convert it to mp3
+	Itag      int    `yaml:"itag" json:"itag,omitempty"`           // exact format, takes precedence over the other preferences
+	MimeType  string `yaml:"mimeType" json:"mimeType,omitempty"`   // eg: video/mp4, video/webm
+	Quality   string `yaml:"quality" json:"quality,omitempty"`     // eg: hd720, 360p
+}
+
+// ConverterConfig are the ffmpeg settings used to convert audio streams
+type ConverterConfig struct {
+	FFmpegPath   string `yaml:"ffmpeg"`       // ffmpeg binary, looked up in PATH by default
+	AudioBitrate string `yaml:"audioBitrate"` // eg: 128k
+}
+
+// ConcurrencyConfig limits the parallel work of the downloader
+type ConcurrencyConfig struct {
+	Connections int    `yaml:"connections"` // max HTTP connections per host
+	Chunks      int    `yaml:"chunks"`      // parallel byte range requests per download, 1 disables chunking
+	ChunkSize   string `yaml:"chunkSize"`   // eg: 10M
+	Downloads   int    `yaml:"downloads"`   // videos downloaded at the same time, sharing the connections
+}
+
+// RetryConfig controls how the requests failed with a 5xx status or a network error are sent again
+type RetryConfig struct {
+	Attempts  int    `yaml:"attempts"`  // requests sent at most, 1 disables the retries
+	BaseDelay string `yaml:"baseDelay"` // delay before the first retry, doubled for every next one, eg: 1s
+	MaxDelay  string `yaml:"maxDelay"`  // longest delay between two attempts, eg: 30s
+}
+
+// ServerConfig are the settings of the download server
+type ServerConfig struct {
+	Users []UserConfig `yaml:"users"` // when empty, the server doesn't require authentication
+}
+
+// UserConfig is a user of the download server, authenticated by a bearer token or basic auth
+type UserConfig struct {
+	Name           string `yaml:"name"`
+	Token          string `yaml:"token,omitempty"`          // sent as "Authorization: Bearer <token>"
+	Password       string `yaml:"password,omitempty"`       // basic auth password of the user name
+	MaxJobs        int    `yaml:"maxJobs,omitempty"`        // max queued and running jobs, unlimited by default
+	MaxBytesPerDay string `yaml:"maxBytesPerDay,omitempty"` // eg: 500M, 10G, unlimited by default
+	Admin          bool   `yaml:"admin,omitempty"`          // sees and cancels the jobs of every user
+}
+
+// DefaultConfig returns the settings used when nothing else is configured
+func DefaultConfig() Config {
+	return Config{
+		HTTPSEnabled:     true,
+		Host:             "youtube.googleapis.com",
+		Port:             443,
+		ClientSecretPath: "./resources/client_secret.json",
+		OutputDir:        ".",
+		Format: FormatConfig{
+			MimeType: "video/mp4",
+		},
+		Converter: ConverterConfig{
+			FFmpegPath:   "ffmpeg",
+			AudioBitrate: "128k",
+		},
+		Concurrency: ConcurrencyConfig{
+			Connections: 5,
+			Chunks:      4,
+			ChunkSize:   "10M",
+			Downloads:   2,
+		},
+		Retry: RetryConfig{
+			Attempts:  4,
+			BaseDelay: "1s",
+			MaxDelay:  "30s",
+		},
+	}
+}
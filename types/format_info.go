@@ -0,0 +1,136 @@
+package types
+
+import (
+	"fmt"
+	"github.com/bit-twit/yt-dl-go/utils"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// FormatKind tells how audio and video are packaged in a format stream
+type FormatKind string
+
+const (
+	FormatKindProgressive FormatKind = "progressive" // audio and video in the same stream
+	FormatKindVideoOnly   FormatKind = "video-only"  // adaptive stream without audio
+	FormatKindAudioOnly   FormatKind = "audio-only"  // adaptive stream without video
+)
+
+// FormatInfo is a flat description of a Format, used to present the formats of a video
+type FormatInfo struct {
+	Itag            int        `json:"itag"`
+	Kind            FormatKind `json:"kind"`
+	MimeType        string     `json:"mimeType"`
+	Container       string     `json:"container"`
+	Codecs          []string   `json:"codecs"`
+	Width           int        `json:"width,omitempty"`
+	Height          int        `json:"height,omitempty"`
+	QualityLabel    string     `json:"qualityLabel,omitempty"`
+	FPS             int        `json:"fps,omitempty"`
+	Bitrate         int        `json:"bitrate"`
+	AverageBitrate  int        `json:"averageBitrate,omitempty"`
+	AudioQuality    string     `json:"audioQuality,omitempty"`
+	AudioSampleRate int        `json:"audioSampleRate,omitempty"`
+	AudioChannels   int        `json:"audioChannels,omitempty"`
+	Size            int64      `json:"size,omitempty"` // bytes, 0 when unknown
+}
+
+// Size returns the stream size in bytes from ContentLength, 0 when unknown
+func (f *Format) Size() int64 {
+	size, _ := strconv.ParseInt(f.ContentLength, 10, 64)
+	return size
+}
+
+// Codecs returns the codecs listed in the mime type, eg: video/mp4; codecs="avc1.42001E, mp4a.40.2"
+func (f *Format) Codecs() []string {
+	_, params, err := mime.ParseMediaType(f.MimeType)
+	if err != nil || params["codecs"] == "" {
+		return nil
+	}
+	codecs := strings.Split(params["codecs"], ",")
+	for i := range codecs {
+		codecs[i] = strings.TrimSpace(codecs[i])
+	}
+	return codecs
+}
+
+// Kind tells if the format is progressive or an adaptive video or audio only stream
+func (f *Format) Kind() FormatKind {
+	if strings.HasPrefix(f.MimeType, "audio/") {
+		return FormatKindAudioOnly
+	}
+	if f.AudioChannels > 0 || f.AudioQuality != "" || len(f.Codecs()) > 1 {
+		return FormatKindProgressive
+	}
+	return FormatKindVideoOnly
+}
+
+// Info returns the flat description of the format
+func (f *Format) Info() FormatInfo {
+	info := FormatInfo{
+		Itag:           f.ItagNo,
+		Kind:           f.Kind(),
+		MimeType:       f.MimeType,
+		Codecs:         f.Codecs(),
+		Width:          f.Width,
+		Height:         f.Height,
+		QualityLabel:   f.QualityLabel,
+		FPS:            f.FPS,
+		Bitrate:        f.Bitrate,
+		AverageBitrate: f.AverageBitrate,
+		AudioQuality:   f.AudioQuality,
+		AudioChannels:  f.AudioChannels,
+		Size:           f.Size(),
+	}
+	if mediaType, _, err := mime.ParseMediaType(f.MimeType); err == nil {
+		if i := strings.Index(mediaType, "/"); i >= 0 {
+			info.Container = mediaType[i+1:]
+		}
+	}
+	info.AudioSampleRate, _ = strconv.Atoi(f.AudioSampleRate)
+	return info
+}
+
+// Infos returns the flat description of every format in the list
+func (list FormatList) Infos() []FormatInfo {
+	infos := make([]FormatInfo, len(list))
+	for i := range list {
+		infos[i] = list[i].Info()
+	}
+	return infos
+}
+
+// WriteTable writes the formats as an aligned text table
+func (list FormatList) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ITAG\tKIND\tMIME TYPE\tCODECS\tRESOLUTION\tFPS\tBITRATE\tAUDIO\tSAMPLE RATE\tSIZE")
+	for _, info := range list.Infos() {
+		mediaType := info.MimeType
+		if i := strings.Index(mediaType, ";"); i >= 0 {
+			mediaType = mediaType[:i]
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			info.Itag,
+			info.Kind,
+			mediaType,
+			strings.Join(info.Codecs, ","),
+			orDash(info.Width > 0, fmt.Sprintf("%dx%d", info.Width, info.Height)),
+			orDash(info.FPS > 0, strconv.Itoa(info.FPS)),
+			orDash(info.Bitrate > 0, fmt.Sprintf("%dk", info.Bitrate/1000)),
+			orDash(info.AudioQuality != "", strings.TrimPrefix(info.AudioQuality, "AUDIO_QUALITY_")),
+			orDash(info.AudioSampleRate > 0, fmt.Sprintf("%dHz", info.AudioSampleRate)),
+			orDash(info.Size > 0, utils.FormatBytes(info.Size)),
+		)
+	}
+	return tw.Flush()
+}
+
+func orDash(ok bool, value string) string {
+	if ok {
+		return value
+	}
+	return "-"
+}
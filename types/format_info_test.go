@@ -0,0 +1,90 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testFormats = FormatList{
+	{
+		ItagNo:          18,
+		MimeType:        `video/mp4; codecs="avc1.42001E, mp4a.40.2"`,
+		Bitrate:         503000,
+		FPS:             30,
+		Width:           640,
+		Height:          360,
+		QualityLabel:    "360p",
+		AudioQuality:    "AUDIO_QUALITY_LOW",
+		AudioSampleRate: "44100",
+		AudioChannels:   2,
+		ContentLength:   "1048576",
+	},
+	{
+		ItagNo:       137,
+		MimeType:     `video/mp4; codecs="avc1.640028"`,
+		Bitrate:      4000000,
+		FPS:          25,
+		Width:        1920,
+		Height:       1080,
+		QualityLabel: "1080p",
+	},
+	{
+		ItagNo:          140,
+		MimeType:        `audio/mp4; codecs="mp4a.40.2"`,
+		Bitrate:         130000,
+		AudioQuality:    "AUDIO_QUALITY_MEDIUM",
+		AudioSampleRate: "44100",
+		AudioChannels:   2,
+		ContentLength:   "4000000",
+	},
+}
+
+func TestFormat_Kind(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(FormatKindProgressive, testFormats[0].Kind())
+	assert.Equal(FormatKindVideoOnly, testFormats[1].Kind())
+	assert.Equal(FormatKindAudioOnly, testFormats[2].Kind())
+}
+
+func TestFormat_Info(t *testing.T) {
+	assert := assert.New(t)
+	info := testFormats[0].Info()
+	assert.Equal(18, info.Itag)
+	assert.Equal("mp4", info.Container)
+	assert.Equal([]string{"avc1.42001E", "mp4a.40.2"}, info.Codecs)
+	assert.Equal(44100, info.AudioSampleRate)
+	assert.Equal(int64(1048576), info.Size)
+
+	assert.Equal(int64(0), testFormats[1].Info().Size)
+}
+
+func TestFormatList_WriteTable(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, testFormats.WriteTable(&buf))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 4)
+	assert.True(t, strings.HasPrefix(lines[0], "ITAG"))
+	assert.Contains(t, lines[1], "progressive")
+	assert.Contains(t, lines[1], "640x360")
+	assert.Contains(t, lines[1], "1.0MiB")
+	assert.Contains(t, lines[2], "video-only")
+	assert.Contains(t, lines[3], "audio-only")
+	assert.Contains(t, lines[3], "MEDIUM")
+}
+
+func TestFormatList_InfosJSON(t *testing.T) {
+	b, err := json.Marshal(testFormats.Infos())
+	require.NoError(t, err)
+
+	var decoded []map[string]interface{}
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	require.Len(t, decoded, 3)
+	assert.Equal(t, "audio-only", decoded[2]["kind"])
+	assert.Equal(t, float64(140), decoded[2]["itag"])
+}
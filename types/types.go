@@ -17,6 +17,12 @@ var (
 	ErrVideoIDMinLength           = errors.New("the video id must be at least 10 characters long")
 	ErrReadOnClosedResBody        = errors.New("http: read on closed response body")
 	ErrNotPlayableInEmbed         = errors.New("embedding of this video has been disabled")
+	ErrFormatNotFound             = errors.New("format not found")
+	ErrVideoIDNotFound            = errors.New("no video id found in url")
+	ErrInvalidPlaylistID          = errors.New("invalid playlist id")
+	ErrNotYoutubeURL              = errors.New("not a youtube url")
+	ErrSizeMismatch               = errors.New("downloaded size doesn't match the content length")
+	ErrArchived                   = errors.New("already in the download archive")
 )
 
 type HttpError struct {
@@ -28,24 +34,59 @@ func (e *HttpError) Error() string {
 	return e.Status + " " + e.Reason
 }
 
-// API HTTP config options
-type Config struct {
-	HTTPSEnabled     bool
-	Host             string
-	Port             int
-	ApiKey           string
-	ClientSecretPath string
+// StatusCode returns the HTTP status as a number, 0 when it isn't one
+func (e *HttpError) StatusCode() int {
+	code, _ := strconv.Atoi(e.Status)
+	return code
+}
+
+// AuthError is returned when the Youtube Data API credentials or OAuth token are rejected or can't be obtained
+type AuthError struct {
+	Err error
+}
+
+func (e *AuthError) Error() string {
+	return "youtube api authentication: " + e.Err.Error()
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
 }
 
 type Video struct {
-	ID              string
-	Title           string
-	Description     string
-	Author          string
-	Duration        time.Duration
-	Formats         FormatList
-	DASHManifestURL string // URI of the DASH manifest file
-	HLSManifestURL  string // URI of the HLS manifest file
+	ID              string        `json:"id"`
+	PlaylistID      string        `json:"playlistId,omitempty"` // playlist the video was listed from
+	Title           string        `json:"title"`
+	Description     string        `json:"description,omitempty"`
+	Author          string        `json:"author,omitempty"`
+	Duration        time.Duration `json:"duration,omitempty"` // encoded in seconds
+	Formats         FormatList    `json:"formats,omitempty"`
+	DASHManifestURL string        `json:"dashManifestUrl,omitempty"` // URI of the DASH manifest file
+	HLSManifestURL  string        `json:"hlsManifestUrl,omitempty"`  // URI of the HLS manifest file
+	ExpiresAt       time.Time     `json:"-"`                         // when the signed stream urls expire, zero when unknown
+}
+
+// MarshalJSON encodes the video with its duration in seconds
+func (v Video) MarshalJSON() ([]byte, error) {
+	type video Video
+	return json.Marshal(struct {
+		video
+		Duration float64 `json:"duration,omitempty"`
+	}{video(v), v.Duration.Seconds()})
+}
+
+// UnmarshalJSON decodes a video encoded by MarshalJSON
+func (v *Video) UnmarshalJSON(b []byte) error {
+	type video Video
+	aux := struct {
+		*video
+		Duration float64 `json:"duration,omitempty"`
+	}{video: (*video)(v)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	v.Duration = time.Duration(aux.Duration * float64(time.Second))
+	return nil
 }
 
 func (v *Video) ParseVideoInfo(body []byte) error {
@@ -140,6 +181,9 @@ func (v *Video) extractDataFromPlayerResponse(prData playerResponseData) error {
 		return errors.New("no formats found in the server's answer")
 	}
 
+	if seconds, err := strconv.Atoi(prData.StreamingData.ExpiresInSeconds); err == nil && seconds > 0 {
+		v.ExpiresAt = time.Now().Add(time.Duration(seconds) * time.Second)
+	}
 	v.HLSManifestURL = prData.StreamingData.HlsManifestURL
 	v.DASHManifestURL = prData.StreamingData.DashManifestURL
 
@@ -175,6 +219,31 @@ func (list FormatList) FindByItag(itagNo int) *Format {
 	return nil
 }
 
+// Select returns the first format matching the preferences, by default the first video/mp4 one.
+// Audio only preferences match the mp4 audio stream (itag 140) unless another itag is set.
+func (list FormatList) Select(pref FormatConfig) *Format {
+	if pref.Itag > 0 {
+		return list.FindByItag(pref.Itag)
+	}
+	if pref.AudioOnly {
+		return list.FindByItag(140)
+	}
+
+	mimeType := pref.MimeType
+	if mimeType == "" {
+		mimeType = "video/mp4"
+	}
+	for i := range list {
+		if !strings.Contains(list[i].MimeType, mimeType) {
+			continue
+		}
+		if pref.Quality == "" || list[i].Quality == pref.Quality || list[i].QualityLabel == pref.Quality {
+			return &list[i]
+		}
+	}
+	return nil
+}
+
 type Format struct {
 	ItagNo           int    `json:"itag"`
 	URL              string `json:"url"`
@@ -0,0 +1,32 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVideo_JSON(t *testing.T) {
+	v := Video{
+		ID:         "BaW_jenozKc",
+		PlaylistID: "PLJhcxmK4B_Nv-tf8OwtuFCr-Xx_1Z3EaR",
+		Title:      "youtube-dl test video",
+		Duration:   10 * time.Second,
+	}
+
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"id": "BaW_jenozKc",
+		"playlistId": "PLJhcxmK4B_Nv-tf8OwtuFCr-Xx_1Z3EaR",
+		"title": "youtube-dl test video",
+		"duration": 10
+	}`, string(b))
+
+	var decoded Video
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, v, decoded)
+}
@@ -0,0 +1,97 @@
+package types
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	videoIDMinLength    = 10
+	playlistIDMinLength = 2 // special playlists have short ids, eg: LL for liked videos
+)
+
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// videoPathPrefixes are the url paths followed by the video id, eg: youtube.com/shorts/<id>
+var videoPathPrefixes = []string{"/shorts/", "/embed/", "/live/", "/v/", "/e/"}
+
+// ExtractVideoID returns the video id of a bare id or of a youtube url in any of the forms:
+// watch?v=<id>, youtu.be/<id>, /shorts/<id>, /embed/<id>, /live/<id> and music.youtube.com/watch?v=<id>
+func ExtractVideoID(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if !strings.ContainsAny(s, "/?=") {
+		return validateVideoID(s)
+	}
+
+	u, err := parseYoutubeURL(s)
+	if err != nil {
+		return "", err
+	}
+
+	if u.Host == "youtu.be" {
+		return validateVideoID(strings.Trim(u.Path, "/"))
+	}
+	if v := u.Query().Get("v"); v != "" {
+		return validateVideoID(v)
+	}
+	for _, prefix := range videoPathPrefixes {
+		if strings.HasPrefix(u.Path, prefix) {
+			id := strings.TrimPrefix(u.Path, prefix)
+			if i := strings.Index(id, "/"); i >= 0 {
+				id = id[:i]
+			}
+			return validateVideoID(id)
+		}
+	}
+	return "", ErrVideoIDNotFound
+}
+
+// ExtractPlaylistID returns the playlist id of a bare id or of the list=<id> parameter of a youtube url
+func ExtractPlaylistID(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if !strings.ContainsAny(s, "/?=") {
+		return validatePlaylistID(s)
+	}
+
+	u, err := parseYoutubeURL(s)
+	if err != nil {
+		return "", err
+	}
+	return validatePlaylistID(u.Query().Get("list"))
+}
+
+// parseYoutubeURL parses the url, with or without scheme, and checks it points to a youtube host
+func parseYoutubeURL(s string) (*url.URL, error) {
+	if !strings.Contains(s, "://") {
+		s = "https://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	u.Host = strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	switch u.Host {
+	case "youtube.com", "m.youtube.com", "music.youtube.com", "youtube-nocookie.com", "youtu.be":
+		return u, nil
+	}
+	return nil, ErrNotYoutubeURL
+}
+
+func validateVideoID(id string) (string, error) {
+	if len(id) < videoIDMinLength {
+		return "", ErrVideoIDMinLength
+	}
+	if !idPattern.MatchString(id) {
+		return "", ErrInvalidCharactersInVideoID
+	}
+	return id, nil
+}
+
+func validatePlaylistID(id string) (string, error) {
+	if len(id) < playlistIDMinLength || !idPattern.MatchString(id) {
+		return "", ErrInvalidPlaylistID
+	}
+	return id, nil
+}
@@ -0,0 +1,67 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractVideoID(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		err   error
+	}{
+		{"BaW_jenozKc", "BaW_jenozKc", nil},
+		{" BaW_jenozKc\n", "BaW_jenozKc", nil},
+		{"https://www.youtube.com/watch?v=BaW_jenozKc", "BaW_jenozKc", nil},
+		{"https://www.youtube.com/watch?v=BaW_jenozKc&list=PLJhcxmK4B_Nv-tf8OwtuFCr-Xx_1Z3EaR&index=2", "BaW_jenozKc", nil},
+		{"youtube.com/watch?feature=share&v=BaW_jenozKc", "BaW_jenozKc", nil},
+		{"https://m.youtube.com/watch?v=BaW_jenozKc", "BaW_jenozKc", nil},
+		{"https://youtu.be/BaW_jenozKc", "BaW_jenozKc", nil},
+		{"https://youtu.be/BaW_jenozKc?t=10", "BaW_jenozKc", nil},
+		{"https://www.youtube.com/shorts/BaW_jenozKc", "BaW_jenozKc", nil},
+		{"https://www.youtube.com/embed/BaW_jenozKc?autoplay=1", "BaW_jenozKc", nil},
+		{"https://www.youtube-nocookie.com/embed/BaW_jenozKc", "BaW_jenozKc", nil},
+		{"https://www.youtube.com/live/BaW_jenozKc?feature=share", "BaW_jenozKc", nil},
+		{"https://music.youtube.com/watch?v=BaW_jenozKc&feature=share", "BaW_jenozKc", nil},
+		{"short", "", ErrVideoIDMinLength},
+		{"BaW_jen$zKc", "", ErrInvalidCharactersInVideoID},
+		{"https://youtu.be/BaW_jen%20zKc", "", ErrInvalidCharactersInVideoID},
+		{"https://www.youtube.com/playlist?list=PLJhcxmK4B_Nv-tf8OwtuFCr-Xx_1Z3EaR", "", ErrVideoIDNotFound},
+		{"https://vimeo.com/watch?v=BaW_jenozKc", "", ErrNotYoutubeURL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ExtractVideoID(tt.input)
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestExtractPlaylistID(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		err   error
+	}{
+		{"PLJhcxmK4B_Nv-tf8OwtuFCr-Xx_1Z3EaR", "PLJhcxmK4B_Nv-tf8OwtuFCr-Xx_1Z3EaR", nil},
+		{"LL", "LL", nil},
+		{"https://www.youtube.com/playlist?list=PLJhcxmK4B_Nv-tf8OwtuFCr-Xx_1Z3EaR", "PLJhcxmK4B_Nv-tf8OwtuFCr-Xx_1Z3EaR", nil},
+		{"https://www.youtube.com/watch?v=BaW_jenozKc&list=PLJhcxmK4B_Nv-tf8OwtuFCr-Xx_1Z3EaR", "PLJhcxmK4B_Nv-tf8OwtuFCr-Xx_1Z3EaR", nil},
+		{"https://music.youtube.com/playlist?list=OLAK5uy_abcdefghij", "OLAK5uy_abcdefghij", nil},
+		{"https://www.youtube.com/watch?v=BaW_jenozKc", "", ErrInvalidPlaylistID},
+		{"PL!invalid", "", ErrInvalidPlaylistID},
+		{"https://example.com/playlist?list=PLJhcxmK4B", "", ErrNotYoutubeURL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ExtractPlaylistID(tt.input)
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBytes(t *testing.T) {
+	for s, expected := range map[string]int64{
+		"1024":   1024,
+		"500K":   500 << 10,
+		"2M":     2 << 20,
+		"2MiB":   2 << 20,
+		"1.5G":   3 << 29,
+		"10gb":   10 << 30,
+		"1T":     1 << 40,
+		" 64k ":  64 << 10,
+		"0":      0,
+		"100B":   100,
+		"0.5KiB": 512,
+	} {
+		n, err := ParseBytes(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, expected, n, s)
+	}
+
+	for _, s := range []string{"", "K", "-1M", "2X", "one"} {
+		_, err := ParseBytes(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "512B", FormatBytes(512))
+	assert.Equal(t, "1.5MiB", FormatBytes(3<<19))
+}
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// TempSuffix ends the names of the files written before being renamed to their final path
+const TempSuffix = ".yt-dl-go.tmp"
+
+// CreateTemp creates an empty temp file in the directory of path, to be renamed to path by CommitTemp
+func CreateTemp(path string) (*os.File, error) {
+	return ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*"+TempSuffix)
+}
+
+// CommitTemp flushes the temp file to the disk and renames it to path, the temp file is removed on failure.
+// path then holds either its previous content or the complete new one, even after a crash.
+func CommitTemp(tempFile, path string, perm os.FileMode) (err error) {
+	defer func() {
+		if err != nil {
+			os.Remove(tempFile)
+		}
+	}()
+	f, err := os.OpenFile(tempFile, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	if err = f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tempFile, path)
+}
+
+// WriteFileAtomic writes data to a temp file and renames it to path, see CommitTemp
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := CreateTemp(path)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return CommitTemp(f.Name(), path, perm)
+}
+
+// SweepTempFiles removes the temp files left in dir and its subdirectories, such as the artist or playlist folders,
+// by interrupted writes. The deeper directories aren't visited. The files modified for the last olderThan may still
+// be written and are kept. It returns the number of files removed.
+func SweepTempFiles(dir string, olderThan time.Duration) (int, error) {
+	removed := 0
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			if rel, relErr := filepath.Rel(dir, path); relErr == nil && strings.Contains(rel, string(filepath.Separator)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(d.Name(), TempSuffix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || time.Since(info.ModTime()) < olderThan {
+			return nil
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "token.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte("old"), 0600))
+
+	require.NoError(t, WriteFileAtomic(path, []byte("new"), 0600))
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(b))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temp file left")
+}
+
+func TestCommitTemp_Failure(t *testing.T) {
+	dir := t.TempDir()
+	f, err := CreateTemp(filepath.Join(dir, "song.mp3"))
+	require.NoError(t, err)
+	f.Close()
+	assert.Contains(t, f.Name(), "song.mp3.")
+	assert.True(t, strings.HasSuffix(f.Name(), TempSuffix))
+
+	// the destination directory doesn't exist
+	err = CommitTemp(f.Name(), filepath.Join(dir, "missing", "song.mp3"), 0644)
+	assert.Error(t, err)
+	assert.NoFileExists(t, f.Name())
+}
+
+func TestSweepTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "Artist", "Album"), 0755))
+	old := time.Now().Add(-2 * time.Hour)
+	files := map[string]time.Time{
+		"Artist/song.mp3.123" + TempSuffix:       old,
+		"Artist/Album/song.mp3.321" + TempSuffix: old,
+		"video.mp4.456" + TempSuffix:             old,
+		"running.mp3.789" + TempSuffix:           time.Now(),
+		"video.mp4.part":                         old,
+		"video.mp4":                              old,
+	}
+	for name, mtime := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(path, []byte("x"), 0644))
+		require.NoError(t, os.Chtimes(path, mtime, mtime))
+	}
+
+	n, err := SweepTempFiles(dir, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.NoFileExists(t, filepath.Join(dir, "Artist/song.mp3.123"+TempSuffix))
+	assert.NoFileExists(t, filepath.Join(dir, "video.mp4.456"+TempSuffix))
+	assert.FileExists(t, filepath.Join(dir, "running.mp3.789"+TempSuffix))
+	assert.FileExists(t, filepath.Join(dir, "Artist/Album/song.mp3.321"+TempSuffix), "too deep")
+	assert.FileExists(t, filepath.Join(dir, "video.mp4.part"))
+	assert.FileExists(t, filepath.Join(dir, "video.mp4"))
+
+	n, err = SweepTempFiles(filepath.Join(dir, "missing"), time.Hour)
+	assert.NoError(t, err)
+	assert.Zero(t, n)
+}